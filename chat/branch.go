@@ -0,0 +1,154 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/mudler/cogito"
+)
+
+// turn is an exchange in the conversation tree: a message sent and the answer
+// to it. Sending a message from a turn that already has one starts a new branch.
+type turn struct {
+	id        int
+	parent    *turn
+	children  []*turn
+	text      string
+	response  string
+	tools     []ToolResult    // Tools run to answer
+	reasoning string          // Last reasoning of the agent before answering
+	fragment  cogito.Fragment // The conversation after the exchange
+}
+
+// Branch describes a branch of the conversation, identified by its last turn
+type Branch struct {
+	ID       int
+	Turns    int    // Number of exchanges in the branch
+	Question string // Last question of the branch
+	Current  bool
+	// At is the number of exchanges the conversation is at in the current
+	// branch, less than Turns after /fork or /retry: the next message then
+	// starts a new branch from there
+	At int
+}
+
+// newTurn starts an exchange from the current turn and makes it the current one
+func (s *Session) newTurn(text string) *turn {
+	s.lastID++
+	t := &turn{id: s.lastID, parent: s.current, text: text, fragment: s.current.fragment}
+	s.current.children = append(s.current.children, t)
+	s.current = t
+	return t
+}
+
+// discard removes a turn, and the branches starting from it, from the conversation
+func (s *Session) discard(t *turn) {
+	siblings := t.parent.children
+	for i, sibling := range siblings {
+		if sibling == t {
+			t.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if s.current == t {
+		s.current = t.parent
+	}
+}
+
+// path returns the turns from the start of the conversation to the current one
+func (s *Session) path() []*turn {
+	turns := []*turn{}
+	for t := s.current; t != s.root; t = t.parent {
+		turns = append([]*turn{t}, turns...)
+	}
+	return turns
+}
+
+// Rewind goes back to before the last message sent, and returns the message
+// so it can be sent again. The exchange is kept as a branch.
+func (s *Session) Rewind() (string, error) {
+	if s.current == s.root {
+		return "", fmt.Errorf("no message sent yet")
+	}
+
+	text := s.current.text
+	s.current = s.current.parent
+	return text, nil
+}
+
+// Fork goes back to after the given number of exchanges, so that the next
+// message starts a new branch. The current branch is kept.
+func (s *Session) Fork(turns int) error {
+	path := s.path()
+	if turns < 0 || turns > len(path) {
+		return fmt.Errorf("the conversation has %d exchanges", len(path))
+	}
+
+	if turns == 0 {
+		s.current = s.root
+	} else {
+		s.current = path[turns-1]
+	}
+	return nil
+}
+
+// Branches returns the branches of the conversation, in the order they were
+// started. The current branch is the one SwitchBranch goes back to from the
+// current turn.
+func (s *Session) Branches() []Branch {
+	branches := []Branch{}
+	current := lastLeaf(s.current)
+	at := len(s.path())
+
+	var walk func(t *turn, depth int)
+	walk = func(t *turn, depth int) {
+		if len(t.children) == 0 && t != s.root {
+			branch := Branch{
+				ID:       t.id,
+				Turns:    depth,
+				Question: t.text,
+				Current:  t == current,
+			}
+			if branch.Current {
+				branch.At = at
+			}
+			branches = append(branches, branch)
+		}
+		for _, child := range t.children {
+			walk(child, depth+1)
+		}
+	}
+	walk(s.root, 0)
+
+	return branches
+}
+
+// SwitchBranch continues the conversation from the end of the given branch
+func (s *Session) SwitchBranch(id int) error {
+	var find func(t *turn) *turn
+	find = func(t *turn) *turn {
+		if t.id == id && t != s.root {
+			return t
+		}
+		for _, child := range t.children {
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+
+	t := find(s.root)
+	if t == nil {
+		return fmt.Errorf("unknown branch %d", id)
+	}
+	s.current = lastLeaf(t)
+	return nil
+}
+
+// lastLeaf returns the end of the branch last started from t
+func lastLeaf(t *turn) *turn {
+	for len(t.children) > 0 {
+		t = t.children[len(t.children)-1]
+	}
+	return t
+}
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExportFormats are the formats a conversation can be exported to
+var ExportFormats = []string{"markdown", "json", "shell"}
+
+// Conversation is an exported conversation: the exchanges of the current branch
+type Conversation struct {
+	Model     string     `json:"model"`
+	Exported  time.Time  `json:"exported"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is a question, the tools run to answer it and the answer
+type Exchange struct {
+	Question string       `json:"question"`
+	Tools    []ToolResult `json:"tools,omitempty"`
+	Answer   string       `json:"answer"`
+}
+
+// Export returns the current branch of the conversation, without the secrets
+// of the config
+func (s *Session) Export() Conversation {
+	redact := s.cfg.Redact
+	c := Conversation{Model: s.cfg.Model, Exported: time.Now(), Exchanges: []Exchange{}}
+	for _, t := range s.path() {
+		tools := make([]ToolResult, len(t.tools))
+		for i, tool := range t.tools {
+			tool.Arguments = redact(tool.Arguments)
+			tool.Output = redact(tool.Output)
+			tool.Stdout = redact(tool.Stdout)
+			tool.Stderr = redact(tool.Stderr)
+			tool.Error = redact(tool.Error)
+			tools[i] = tool
+		}
+		c.Exchanges = append(c.Exchanges, Exchange{
+			Question: redact(t.text),
+			Tools:    tools,
+			Answer:   redact(t.response),
+		})
+	}
+	return c
+}
+
+// ReadConversation reads a conversation exported as JSON
+func ReadConversation(path string) (Conversation, error) {
+	var c Conversation
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("failed to parse %s, only JSON exports can be converted: %w", path, err)
+	}
+	return c, nil
+}
+
+// Format renders the conversation in one of the ExportFormats
+func (c Conversation) Format(format string) (string, error) {
+	switch format {
+	case "markdown", "md":
+		return c.Markdown(), nil
+	case "json":
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "shell", "sh":
+		return c.ShellScript(), nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, expected one of: %s", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+// Markdown renders the conversation as a readable Markdown document
+func (c Conversation) Markdown() string {
+	var sb strings.Builder
+	for _, e := range c.Exchanges {
+		sb.WriteString("## 👤 You\n\n")
+		sb.WriteString(e.Question)
+		sb.WriteString("\n\n")
+
+		for _, tool := range e.Tools {
+			sb.WriteString(fmt.Sprintf("### 🔧 %s", tool.Name))
+			if tool.IsCommand() {
+				sb.WriteString(fmt.Sprintf(" (exit %d)", *tool.ExitCode))
+			}
+			sb.WriteString("\n\n")
+			if script := tool.Script(); script != "" {
+				sb.WriteString(codeBlock("sh", strings.TrimRight(script, "\n")))
+			} else {
+				sb.WriteString(codeBlock("json", tool.Arguments))
+			}
+
+			output := tool.Output
+			if tool.IsCommand() {
+				output = strings.TrimSpace(tool.Stdout + "\n" + tool.Stderr)
+			}
+			if output = strings.TrimSpace(output); output != "" {
+				sb.WriteString(codeBlock("", output))
+			}
+		}
+
+		if e.Answer != "" {
+			sb.WriteString("## 🧙 Wiz\n\n")
+			sb.WriteString(e.Answer)
+			sb.WriteString("\n\n")
+		}
+	}
+	return sb.String()
+}
+
+// codeBlock renders content as a fenced Markdown code block. The fence is
+// longer than any backtick run in content, so that it can't close it early.
+func codeBlock(lang, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + content + "\n" + fence + "\n\n"
+}
+
+// ShellScript renders the scripts run by the bash tool, in order, as a shell
+// script that replays the session
+func (c Conversation) ShellScript() string {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString(fmt.Sprintf("# Generated by wiz on %s\n", c.Exported.Format(time.RFC1123)))
+
+	for _, e := range c.Exchanges {
+		scripts := []string{}
+		for _, tool := range e.Tools {
+			if script := tool.Script(); tool.Name == "bash" && script != "" {
+				scripts = append(scripts, strings.TrimRight(script, "\n"))
+			}
+		}
+		if len(scripts) == 0 {
+			continue
+		}
+
+		sb.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSpace(e.Question), "\n") {
+			sb.WriteString("# " + line + "\n")
+		}
+		for _, script := range scripts {
+			sb.WriteString(script + "\n")
+		}
+	}
+	return sb.String()
+}
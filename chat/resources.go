@@ -0,0 +1,221 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito/pkg/xlog"
+)
+
+// ResourceInfo describes a resource exposed by one of the MCP servers
+type ResourceInfo struct {
+	URI         string
+	Name        string
+	Description string
+	MIMEType    string
+	Server      string
+}
+
+// PromptInfo describes a prompt exposed by one of the MCP servers
+type PromptInfo struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+	Server      string
+}
+
+// PromptArgument describes an argument of a prompt
+type PromptArgument struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// mentionPattern matches the @resource mentions of a message
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// ListResources returns the resources exposed by the MCP servers supporting them
+func (s *Session) ListResources() ([]ResourceInfo, error) {
+	resources := []ResourceInfo{}
+	for _, sc := range s.clients() {
+		if caps := sc.client.InitializeResult().Capabilities; caps == nil || caps.Resources == nil {
+			continue
+		}
+
+		for resource, err := range sc.client.Resources(s.ctx, nil) {
+			if err != nil {
+				xlog.Warn("Failed to list resources", "server", sc.name, "error", err)
+				break
+			}
+			resources = append(resources, ResourceInfo{
+				URI:         resource.URI,
+				Name:        resource.Name,
+				Description: resource.Description,
+				MIMEType:    resource.MIMEType,
+				Server:      sc.name,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// findResource finds a resource by URI or name
+func findResource(resources []ResourceInfo, ref string) (ResourceInfo, bool) {
+	for _, resource := range resources {
+		if resource.URI == ref {
+			return resource, true
+		}
+	}
+	for _, resource := range resources {
+		if resource.Name == ref {
+			return resource, true
+		}
+	}
+	return ResourceInfo{}, false
+}
+
+// ReadResource returns the contents of a resource, given its URI or name
+func (s *Session) ReadResource(ref string) (string, error) {
+	resources, err := s.ListResources()
+	if err != nil {
+		return "", err
+	}
+	resource, ok := findResource(resources, ref)
+	if !ok {
+		return "", fmt.Errorf("unknown resource %q", ref)
+	}
+	return s.readResource(resource)
+}
+
+// readResource reads a resource from the server exposing it
+func (s *Session) readResource(resource ResourceInfo) (string, error) {
+	for _, sc := range s.clients() {
+		if sc.name != resource.Server {
+			continue
+		}
+
+		res, err := sc.client.ReadResource(s.ctx, &mcp.ReadResourceParams{URI: resource.URI})
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", resource.URI, err)
+		}
+
+		var sb strings.Builder
+		for _, content := range res.Contents {
+			if content.Text != "" {
+				sb.WriteString(content.Text)
+			} else if len(content.Blob) > 0 {
+				// Binary contents can't be given to the model as text
+				sb.WriteString(fmt.Sprintf("(binary content, %s, %d bytes)", content.MIMEType, len(content.Blob)))
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("MCP server %s is not running", resource.Server)
+}
+
+// attachResources appends the contents of the resources mentioned with
+// @uri or @name to the message. Mentions of unknown resources are left alone.
+func (s *Session) attachResources(text string) string {
+	matches := mentionPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	resources, err := s.ListResources()
+	if err != nil || len(resources) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.WriteString(text)
+	attached := map[string]bool{}
+	for _, match := range matches {
+		ref := strings.TrimRight(match[1], ".,;:!?")
+		resource, ok := findResource(resources, ref)
+		if !ok || attached[resource.URI] {
+			continue
+		}
+		attached[resource.URI] = true
+
+		content, err := s.readResource(resource)
+		if err != nil {
+			xlog.Warn("Failed to attach resource", "uri", resource.URI, "error", err)
+			if s.callbacks.OnWarning != nil {
+				s.callbacks.OnWarning(fmt.Sprintf("Could not attach %s: %v", resource.URI, err))
+			}
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n\n<resource uri=%q>\n%s\n</resource>", resource.URI, strings.TrimRight(s.cfg.Redact(content), "\n")))
+	}
+	return sb.String()
+}
+
+// ListPrompts returns the prompts exposed by the MCP servers supporting them.
+// Prompts with the name of one from another server are skipped.
+func (s *Session) ListPrompts() ([]PromptInfo, error) {
+	prompts := []PromptInfo{}
+	seen := map[string]string{}
+	for _, sc := range s.clients() {
+		if caps := sc.client.InitializeResult().Capabilities; caps == nil || caps.Prompts == nil {
+			continue
+		}
+
+		for prompt, err := range sc.client.Prompts(s.ctx, nil) {
+			if err != nil {
+				xlog.Warn("Failed to list prompts", "server", sc.name, "error", err)
+				break
+			}
+			if other, ok := seen[prompt.Name]; ok {
+				xlog.Warn("Prompt name already used by another server, skipping it", "prompt", prompt.Name, "server", sc.name, "other", other)
+				continue
+			}
+			seen[prompt.Name] = sc.name
+
+			info := PromptInfo{
+				Name:        prompt.Name,
+				Description: prompt.Description,
+				Server:      sc.name,
+			}
+			for _, arg := range prompt.Arguments {
+				info.Arguments = append(info.Arguments, PromptArgument{
+					Name:        arg.Name,
+					Description: arg.Description,
+					Required:    arg.Required,
+				})
+			}
+			prompts = append(prompts, info)
+		}
+	}
+	return prompts, nil
+}
+
+// GetPrompt renders a prompt of the given server with its arguments, and
+// returns the text of its messages
+func (s *Session) GetPrompt(server, name string, args map[string]string) (string, error) {
+	for _, sc := range s.clients() {
+		if sc.name != server {
+			continue
+		}
+
+		res, err := sc.client.GetPrompt(s.ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+		if err != nil {
+			return "", fmt.Errorf("failed to get prompt %s: %w", name, err)
+		}
+
+		parts := []string{}
+		for _, msg := range res.Messages {
+			switch content := msg.Content.(type) {
+			case *mcp.TextContent:
+				parts = append(parts, content.Text)
+			case *mcp.EmbeddedResource:
+				if content.Resource != nil && content.Resource.Text != "" {
+					parts = append(parts, fmt.Sprintf("<resource uri=%q>\n%s\n</resource>", content.Resource.URI, strings.TrimRight(content.Resource.Text, "\n")))
+				}
+			}
+		}
+		return s.cfg.Redact(strings.Join(parts, "\n\n")), nil
+	}
+	return "", fmt.Errorf("MCP server %s is not running", server)
+}
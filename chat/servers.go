@@ -0,0 +1,214 @@
+package chat
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito/pkg/xlog"
+)
+
+// States of an MCP server
+const (
+	ServerStopped    = "stopped"    // Not connected yet
+	ServerRunning    = "running"    // Connected
+	ServerFailed     = "failed"     // Connecting failed, retried on next use
+	ServerRestarting = "restarting" // Crashed, being restarted
+)
+
+// Backoff between attempts to connect a failed or crashed server
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// ServerStatus describes the state of a connected MCP server
+type ServerStatus struct {
+	Name     string
+	State    string
+	PID      int    // Process of the server, 0 if it doesn't run locally
+	Error    string // Last connection error
+	Stderr   string // End of the error output of the server process
+	Restarts int
+	Tools    []ToolInfo
+}
+
+// serverInfo is implemented by transports that describe the server they
+// connect to, like the ones built by the mcp package
+type serverInfo interface {
+	Name() string
+	PID() int
+	Stderr() string
+}
+
+// server tracks the connection to an MCP server
+type server struct {
+	transport mcp.Transport
+
+	mu          sync.Mutex
+	client      *mcp.ClientSession
+	state       string
+	err         error
+	restarts    int
+	backoff     time.Duration
+	retryAt     time.Time
+	connectedAt time.Time
+}
+
+// failed records a failed attempt and returns how long to wait before the next one
+func (srv *server) failed(err error) time.Duration {
+	srv.err = err
+	srv.backoff = min(max(2*srv.backoff, minBackoff), maxBackoff)
+	srv.retryAt = time.Now().Add(srv.backoff)
+	return srv.backoff
+}
+
+// name returns the name of the server, or a placeholder for anonymous transports
+func (srv *server) name() string {
+	if info, ok := srv.transport.(serverInfo); ok {
+		return info.Name()
+	}
+	return "mcp"
+}
+
+// connect connects the server if it isn't, unless it failed too recently.
+// Failures are logged, not returned: the session goes on without the server.
+func (s *Session) connect(srv *server) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.client != nil || time.Now().Before(srv.retryAt) {
+		return
+	}
+
+	client, err := s.mcpClient.Connect(s.ctx, srv.transport, nil)
+	if err != nil {
+		// Only warn the first time, not on every retry
+		warn := srv.state != ServerFailed
+		srv.state = ServerFailed
+		srv.failed(err)
+		xlog.Warn("Failed to connect to MCP server", "server", srv.name(), "error", err)
+		if warn && s.callbacks.OnWarning != nil {
+			s.callbacks.OnWarning(fmt.Sprintf("MCP server %s is unavailable, going on without it: %v", srv.name(), err))
+		}
+		return
+	}
+
+	srv.client = client
+	srv.state = ServerRunning
+	srv.err = nil
+	srv.connectedAt = time.Now()
+	go s.supervise(srv, client)
+}
+
+// supervise restarts the server when its connection is lost
+func (s *Session) supervise(srv *server, client *mcp.ClientSession) {
+	err := client.Wait()
+
+	srv.mu.Lock()
+	if s.ctx.Err() != nil || srv.client != client {
+		// Closed on purpose
+		srv.mu.Unlock()
+		return
+	}
+	srv.client = nil
+	srv.state = ServerRestarting
+	srv.restarts++
+	if time.Since(srv.connectedAt) > maxBackoff {
+		// The server was stable for a while, don't hold past crashes against it
+		srv.backoff = 0
+	}
+	delay := srv.failed(err)
+	srv.mu.Unlock()
+
+	xlog.Warn("MCP server stopped, restarting", "server", srv.name(), "error", err, "delay", delay)
+	if s.callbacks.OnWarning != nil {
+		s.callbacks.OnWarning(fmt.Sprintf("MCP server %s stopped, restarting it in %s", srv.name(), delay))
+	}
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(delay):
+		s.connect(srv)
+	}
+}
+
+// serverClient is the session of a running MCP server
+type serverClient struct {
+	name   string
+	client *mcp.ClientSession
+}
+
+// clients returns the sessions of the running servers, connecting them if needed
+func (s *Session) clients() []serverClient {
+	clients := []serverClient{}
+	for _, srv := range s.servers {
+		s.connect(srv)
+
+		srv.mu.Lock()
+		client := srv.client
+		srv.mu.Unlock()
+		if client != nil {
+			clients = append(clients, serverClient{name: srv.name(), client: client})
+		}
+	}
+	return clients
+}
+
+// Servers returns the status of the MCP servers, connecting them if needed
+func (s *Session) Servers() []ServerStatus {
+	statuses := []ServerStatus{}
+	for _, srv := range s.servers {
+		s.connect(srv)
+
+		srv.mu.Lock()
+		status := ServerStatus{
+			Name:     srv.name(),
+			State:    srv.state,
+			Restarts: srv.restarts,
+		}
+		if srv.err != nil {
+			status.Error = srv.err.Error()
+		}
+		srv.mu.Unlock()
+
+		if info, ok := srv.transport.(serverInfo); ok {
+			status.PID = info.PID()
+			status.Stderr = info.Stderr()
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Tools as given to the agent, filtered and prefixed
+	for _, tool := range s.tools(s.ctx) {
+		for i := range statuses {
+			if statuses[i].Name == tool.server {
+				statuses[i].Tools = append(statuses[i].Tools, ToolInfo{
+					Name:        tool.name,
+					Server:      tool.server,
+					Description: tool.tool.Description,
+				})
+			}
+		}
+	}
+	return statuses
+}
+
+// closeServers disconnects all the servers
+func (s *Session) closeServers() error {
+	var firstErr error
+	for _, srv := range s.servers {
+		srv.mu.Lock()
+		client := srv.client
+		srv.client = nil
+		srv.state = ServerStopped
+		srv.mu.Unlock()
+
+		if client != nil {
+			if err := client.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -6,23 +6,26 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"sort"
 
+	"github.com/mudler/wiz/config"
 	"github.com/mudler/wiz/types"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/mudler/cogito"
-	openai "github.com/sashabaranov/go-openai"
 )
 
 // Message represents a chat message
 type Message struct {
 	Role    string
 	Content string
+	Tool    *ToolResult // For "tool" messages, the result of the tool run
 }
 
 // ToolCallRequest contains information about a tool the agent wants to run
 type ToolCallRequest struct {
 	Name      string
+	Server    string // MCP server providing the tool
 	Arguments string
 	Reasoning string
 }
@@ -34,6 +37,13 @@ type ToolCallResponse struct {
 	AlwaysAllow bool // Add tool to session allow list
 }
 
+// ToolInfo describes a tool exposed by one of the connected MCP servers
+type ToolInfo struct {
+	Name        string
+	Server      string
+	Description string
+}
+
 // Callbacks defines the interface for UI interactions
 type Callbacks struct {
 	// OnStatus is called when there's a status update
@@ -43,19 +53,27 @@ type Callbacks struct {
 	// OnToolCall is called when the agent wants to run a tool
 	// Returns the user's decision
 	OnToolCall func(req ToolCallRequest) ToolCallResponse
+	// OnToolResult is called when a tool has been run
+	OnToolResult func(result ToolResult)
 	// OnResponse is called when the agent responds
 	OnResponse func(response string)
 	// OnError is called when an error occurs
 	OnError func(err error)
+	// OnWarning is called for problems the session goes on with, like an
+	// MCP server failing
+	OnWarning func(message string)
 }
 
 // Session represents a chat session with the AI assistant
 type Session struct {
 	ctx           context.Context
+	cfg           types.Config
 	llm           cogito.LLM
-	clients       []*mcp.ClientSession
-	fragment      cogito.Fragment
-	messages      []openai.ChatCompletionMessage
+	mcpClient     *mcp.Client
+	servers       []*server
+	root          *turn // Start of the conversation tree
+	current       *turn // Last exchange of the current branch
+	lastID        int
 	callbacks     Callbacks
 	systemPrompt  string
 	cogitoOptions types.AgentOptions
@@ -76,49 +94,101 @@ func CommandTransport(cmd string, args []string, env ...string) mcp.Transport {
 func NewSession(ctx context.Context, cfg types.Config, callbacks Callbacks, transports ...mcp.Transport) (*Session, error) {
 	llm := cogito.NewOpenAILLM(cfg.Model, cfg.APIKey, cfg.BaseURL)
 
-	client := mcp.NewClient(&mcp.Implementation{Name: "aish", Version: "v1.0.0"}, nil)
-	clients := []*mcp.ClientSession{}
-
+	// MCP servers are connected on first use, and the session goes on without
+	// the ones failing
+	servers := []*server{}
 	for _, transport := range transports {
-		session, err := client.Connect(ctx, transport, nil)
-		if err != nil {
-			return nil, err
-		}
-		clients = append(clients, session)
+		servers = append(servers, &server{transport: transport, state: ServerStopped})
 	}
 
+	root := &turn{fragment: cogito.NewEmptyFragment()}
 	return &Session{
 		ctx:           ctx,
+		cfg:           cfg,
 		llm:           llm,
-		clients:       clients,
-		fragment:      cogito.NewEmptyFragment(),
-		messages:      []openai.ChatCompletionMessage{},
+		mcpClient:     mcp.NewClient(&mcp.Implementation{Name: "aish", Version: "v1.0.0"}, nil),
+		servers:       servers,
+		root:          root,
+		current:       root,
 		callbacks:     callbacks,
-		systemPrompt:  cfg.GetPrompt(),
+		systemPrompt:  config.SystemPrompt(cfg),
 		cogitoOptions: cfg.AgentOptions,
 		allowedTools:  make(map[string]bool),
 	}, nil
 }
 
 func (s *Session) ClearHistory() {
-	s.messages = []openai.ChatCompletionMessage{}
-	s.fragment = cogito.NewEmptyFragment()
+	s.root = &turn{fragment: cogito.NewEmptyFragment()}
+	s.current = s.root
+}
+
+// Model returns the model currently used by the session
+func (s *Session) Model() string {
+	return s.cfg.Model
+}
+
+// SetModel switches the model used for the next messages, keeping the conversation
+func (s *Session) SetModel(model string) {
+	s.cfg.Model = model
+	s.llm = cogito.NewOpenAILLM(model, s.cfg.APIKey, s.cfg.BaseURL)
+}
+
+// AllowTool adds a tool to the session allow list
+func (s *Session) AllowTool(name string) {
+	s.allowedTools[name] = true
+}
+
+// AllowedTools returns the tools that don't need approval, sorted by name
+func (s *Session) AllowedTools() []string {
+	tools := []string{}
+	for name, allowed := range s.allowedTools {
+		if allowed {
+			tools = append(tools, name)
+		}
+	}
+	sort.Strings(tools)
+	return tools
+}
+
+// ListTools returns the tools exposed by all the connected MCP servers
+func (s *Session) ListTools() ([]ToolInfo, error) {
+	tools := []ToolInfo{}
+	for _, tool := range s.tools(s.ctx) {
+		tools = append(tools, ToolInfo{
+			Name:        tool.name,
+			Server:      tool.server,
+			Description: tool.tool.Description,
+		})
+	}
+	return tools, nil
 }
 
 // SendMessage sends a message to the assistant and processes the response
 func (s *Session) SendMessage(text string) (string, error) {
+	return s.SendMessageContext(s.ctx, text)
+}
+
+// SendMessageContext is like SendMessage, but the request, including any tool
+// it runs, is interrupted when ctx is cancelled. The conversation is then left
+// as it was before the message was sent.
+func (s *Session) SendMessageContext(ctx context.Context, text string) (string, error) {
+	t := s.newTurn(text)
 	if s.systemPrompt != "" {
-		s.fragment = s.fragment.AddMessage("system", s.systemPrompt)
+		t.fragment = t.fragment.AddMessage("system", s.systemPrompt)
+	}
+	// The model gets the contents of the mentioned resources, the transcript
+	// keeps the message as typed
+	t.fragment = t.fragment.AddMessage("user", s.attachResources(text))
+
+	tools := s.tools(ctx)
+	servers := map[string]string{}
+	for _, tool := range tools {
+		servers[tool.name] = tool.server
 	}
-	s.fragment = s.fragment.AddMessage("user", text)
-	s.messages = append(s.messages, openai.ChatCompletionMessage{
-		Role:    "user",
-		Content: text,
-	})
 
 	// Build cogito options from config
 	cogitoOpts := []cogito.Option{
-		cogito.WithContext(s.ctx),
+		cogito.WithContext(ctx),
 		cogito.WithIterations(s.cogitoOptions.Iterations),
 		cogito.WithMaxAttempts(s.cogitoOptions.MaxAttempts),
 		cogito.WithMaxRetries(s.cogitoOptions.MaxRetries),
@@ -128,11 +198,20 @@ func (s *Session) SendMessage(text string) (string, error) {
 			}
 		}),
 		cogito.WithReasoningCallback(func(reasoning string) {
+			t.reasoning = reasoning
 			if s.callbacks.OnReasoning != nil {
 				s.callbacks.OnReasoning(reasoning)
 			}
 		}),
-		cogito.WithMCPs(s.clients...),
+		cogito.WithTools(agentTools(tools)...),
+		cogito.WithToolCallResultCallback(func(status cogito.ToolStatus) {
+			args, _ := json.Marshal(status.ToolArguments.Arguments)
+			result := newToolResult(status.Name, string(args), status.Result)
+			t.tools = append(t.tools, result)
+			if s.callbacks.OnToolResult != nil {
+				s.callbacks.OnToolResult(result)
+			}
+		}),
 		cogito.WithToolCallBack(func(tool *cogito.ToolChoice, state *cogito.SessionState) cogito.ToolCallDecision {
 			// Check if tool is in the allow list
 			if s.allowedTools[tool.Name] {
@@ -150,6 +229,7 @@ func (s *Session) SendMessage(text string) (string, error) {
 
 			resp := s.callbacks.OnToolCall(ToolCallRequest{
 				Name:      tool.Name,
+				Server:    servers[tool.Name],
 				Arguments: string(args),
 				Reasoning: tool.Reasoning,
 			})
@@ -171,12 +251,15 @@ func (s *Session) SendMessage(text string) (string, error) {
 		cogitoOpts = append(cogitoOpts, cogito.WithForceReasoning())
 	}
 
-	var err error
-	s.fragment, err = cogito.ExecuteTools(
-		s.llm, s.fragment,
+	fragment, err := cogito.ExecuteTools(
+		s.llm, t.fragment,
 		cogitoOpts...,
 	)
 
+	if ctx.Err() != nil {
+		s.discard(t)
+		return "", ctx.Err()
+	}
 	if err != nil && !errors.Is(err, cogito.ErrNoToolSelected) {
 		if s.callbacks.OnError != nil {
 			s.callbacks.OnError(err)
@@ -184,7 +267,11 @@ func (s *Session) SendMessage(text string) (string, error) {
 		return "", err
 	}
 
-	s.fragment, err = s.llm.Ask(context.Background(), s.fragment)
+	fragment, err = s.llm.Ask(ctx, fragment)
+	if ctx.Err() != nil {
+		s.discard(t)
+		return "", ctx.Err()
+	}
 	if err != nil {
 		if s.callbacks.OnError != nil {
 			s.callbacks.OnError(err)
@@ -192,11 +279,9 @@ func (s *Session) SendMessage(text string) (string, error) {
 		return "", err
 	}
 
-	response := s.fragment.LastMessage().Content
-	s.messages = append(s.messages, openai.ChatCompletionMessage{
-		Role:    "assistant",
-		Content: response,
-	})
+	response := fragment.LastMessage().Content
+	t.fragment = fragment
+	t.response = response
 
 	if s.callbacks.OnResponse != nil {
 		s.callbacks.OnResponse(response)
@@ -205,24 +290,27 @@ func (s *Session) SendMessage(text string) (string, error) {
 	return response, nil
 }
 
-// GetMessages returns all messages in the conversation
+// GetMessages returns all messages in the current branch of the conversation:
+// each question is followed by the "tool" messages of the tools run to
+// answer it, the last "reasoning" of the agent and the answer
 func (s *Session) GetMessages() []Message {
 	messages := []Message{}
-	for _, msg := range s.messages {
-		messages = append(messages, Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+	for _, t := range s.path() {
+		messages = append(messages, Message{Role: "user", Content: t.text})
+		for i := range t.tools {
+			messages = append(messages, Message{Role: "tool", Tool: &t.tools[i]})
+		}
+		if t.response != "" {
+			if t.reasoning != "" {
+				messages = append(messages, Message{Role: "reasoning", Content: t.reasoning})
+			}
+			messages = append(messages, Message{Role: "assistant", Content: t.response})
+		}
 	}
 	return messages
 }
 
 // Close closes the session and cleans up resources
 func (s *Session) Close() error {
-	for _, client := range s.clients {
-		if err := client.Close(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return s.closeServers()
 }
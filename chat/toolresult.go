@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ToolResult contains the outcome of a tool run by the agent
+type ToolResult struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	// Output is the raw result returned by the tool
+	Output string `json:"output"`
+	// Stdout, Stderr and ExitCode are set for tools running commands,
+	// like the built-in shell tool
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// commandOutput is the result of tools running commands
+type commandOutput struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode *int   `json:"exit_code"`
+	Error    string `json:"error"`
+}
+
+// newToolResult builds a ToolResult, extracting the command output when the
+// tool returned one
+func newToolResult(name, arguments, output string) ToolResult {
+	result := ToolResult{
+		Name:      name,
+		Arguments: arguments,
+		Output:    output,
+	}
+
+	var cmd commandOutput
+	if err := json.Unmarshal([]byte(output), &cmd); err == nil && cmd.ExitCode != nil {
+		result.Stdout = cmd.Stdout
+		result.Stderr = cmd.Stderr
+		result.ExitCode = cmd.ExitCode
+		result.Error = cmd.Error
+	}
+	return result
+}
+
+// IsCommand returns true if the result comes from a tool running a command
+func (r ToolResult) IsCommand() bool {
+	return r.ExitCode != nil
+}
+
+// Failed returns true if the command run by the tool failed
+func (r ToolResult) Failed() bool {
+	return r.ExitCode != nil && *r.ExitCode != 0
+}
+
+// Script returns the script run by the tool, for tools taking one like the
+// built-in shell tool
+func (r ToolResult) Script() string {
+	var args struct {
+		Script string `json:"script"`
+	}
+	if err := json.Unmarshal([]byte(r.Arguments), &args); err != nil {
+		return ""
+	}
+	return args.Script
+}
+
+// TrimLines keeps the first max lines of text, noting how many were left out
+func TrimLines(text string, max int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) <= max {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:max], "\n") + fmt.Sprintf("\n… (%d more lines)", len(lines)-max)
+}
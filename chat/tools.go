@@ -0,0 +1,129 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito"
+	"github.com/mudler/cogito/pkg/xlog"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// mcpTool is a tool of an MCP server, as given to the agent. Its name is
+// prefixed as configured for the server.
+type mcpTool struct {
+	ctx    context.Context
+	client *mcp.ClientSession
+	server string
+	name   string // Name exposed to the agent
+	tool   *mcp.Tool
+	redact func(string) string // Hides secrets from the results
+}
+
+// Tool implements cogito.ToolDefinitionInterface
+func (t *mcpTool) Tool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        t.name,
+			Description: t.tool.Description,
+			Parameters:  t.tool.InputSchema,
+		},
+	}
+}
+
+// Execute implements cogito.ToolDefinitionInterface
+func (t *mcpTool) Execute(args map[string]any) (string, error) {
+	res, err := t.client.CallTool(t.ctx, &mcp.CallToolParams{
+		Name:      t.tool.Name,
+		Arguments: args,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, content := range res.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			sb.WriteString(text.Text)
+		}
+	}
+	result := t.redact(sb.String())
+
+	if res.IsError {
+		return result, errors.New("tool failed: " + result)
+	}
+	return result, nil
+}
+
+// MatchesTool returns true if a tool name matches one of patterns, tool
+// names or patterns like "write_*"
+func MatchesTool(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toolEnabled returns true if the tool of a server should be given to the
+// agent. Lists hold tool names or patterns like "write_*".
+func toolEnabled(name string, enabled, disabled []string) bool {
+	if len(enabled) > 0 && !MatchesTool(name, enabled) {
+		return false
+	}
+	return !MatchesTool(name, disabled)
+}
+
+// tools returns the enabled tools of the running MCP servers. ctx bounds the
+// tool calls.
+func (s *Session) tools(ctx context.Context) []*mcpTool {
+	tools := []*mcpTool{}
+	seen := map[string]string{}
+
+	for _, sc := range s.clients() {
+		name := sc.name
+		opts := s.cfg.MCPServers[name]
+
+		res, err := sc.client.ListTools(ctx, nil)
+		if err != nil {
+			xlog.Warn("Failed to list tools", "server", name, "error", err)
+			continue
+		}
+		for _, tool := range res.Tools {
+			if !toolEnabled(tool.Name, opts.EnabledTools, opts.DisabledTools) {
+				continue
+			}
+
+			exposed := opts.ToolPrefix + tool.Name
+			if other, ok := seen[exposed]; ok {
+				xlog.Warn("Tool name already used by another server, skipping it", "tool", exposed, "server", name, "other", other)
+				continue
+			}
+			seen[exposed] = name
+
+			tools = append(tools, &mcpTool{
+				ctx:    ctx,
+				client: sc.client,
+				server: name,
+				name:   exposed,
+				tool:   tool,
+				redact: s.cfg.Redact,
+			})
+		}
+	}
+	return tools
+}
+
+// agentTools converts tools for cogito
+func agentTools(tools []*mcpTool) []cogito.ToolDefinitionInterface {
+	defs := make([]cogito.ToolDefinitionInterface, len(tools))
+	for i, tool := range tools {
+		defs[i] = tool
+	}
+	return defs
+}
@@ -3,14 +3,21 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/charmbracelet/x/term"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/mudler/wiz/chat"
+	"github.com/mudler/wiz/commands"
+	"github.com/mudler/wiz/config"
 	"github.com/mudler/wiz/types"
 )
 
@@ -26,6 +33,9 @@ const (
 	colorPurple = "\033[35m"
 )
 
+// maxToolOutputLines bounds the lines of tool output printed
+const maxToolOutputLines = 20
+
 // Spinner frames for animated display
 var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 
@@ -99,29 +109,297 @@ func (s *spinner) stop() {
 	<-s.doneChan
 }
 
-// readStringCancellable reads a line from the reader, but can be cancelled via context
-func readStringCancellable(ctx context.Context, reader *bufio.Reader) (string, error) {
-	type result struct {
-		text string
-		err  error
+// lineReader reads lines from stdin in the background, so that reading can be
+// cancelled without losing the next line typed. On a terminal, lines are
+// edited in raw mode, to complete slash commands with Tab.
+type lineReader struct {
+	lines     chan line   // Lines, when stdin isn't a terminal
+	input     chan []byte // Raw input, when it is
+	pending   []byte      // Raw input not handled yet, typed ahead
+	initial   string      // Text the next line starts with, when edited
+	interrupt func()      // Called on Ctrl+C, which raw mode doesn't turn into a signal
+}
+
+type line struct {
+	text string
+	err  error
+}
+
+func newLineReader(interrupt func()) *lineReader {
+	r := &lineReader{interrupt: interrupt}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		r.lines = make(chan line)
+		go func() {
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				text, err := reader.ReadString('\n')
+				r.lines <- line{text: text, err: err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+		return r
+	}
+
+	r.input = make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				r.input <- buf[:n]
+			}
+			if err != nil {
+				close(r.input)
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// prefill makes the next line start with text, to be edited. It returns
+// false when stdin isn't a terminal, as the line can't be edited then.
+func (r *lineReader) prefill(text string) bool {
+	if r.input == nil {
+		return false
+	}
+	r.initial = text
+	return true
+}
+
+// readLine prints prompt and reads a line, but can be cancelled via context.
+// complete, if set, returns the completions of the line for Tab.
+func (r *lineReader) readLine(ctx context.Context, prompt string, complete func(string) []string) (string, error) {
+	fmt.Print(prompt)
+	if r.input == nil {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case l := <-r.lines:
+			return l.text, l.err
+		}
+	}
+
+	fd := os.Stdin.Fd()
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+
+	editor := &lineEditor{prompt: prompt, buf: []rune(r.initial), complete: complete}
+	fmt.Print(r.initial)
+	r.initial = ""
+	for {
+		for len(r.pending) > 0 {
+			n, key := nextKey(r.pending)
+			if n == 0 {
+				// Incomplete key, wait for the rest
+				break
+			}
+			r.pending = r.pending[n:]
+
+			switch key {
+			case "\r", "\n":
+				fmt.Print("\r\n")
+				return string(editor.buf) + "\n", nil
+			case "\x03": // Ctrl+C
+				term.Restore(fd, state)
+				r.interrupt()
+				return "", context.Canceled
+			case "\x04": // Ctrl+D
+				if len(editor.buf) == 0 {
+					fmt.Print("\r\n")
+					return "", io.EOF
+				}
+			default:
+				editor.handle(key)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Print("\r\n")
+			return "", ctx.Err()
+		case data, ok := <-r.input:
+			if !ok {
+				return "", io.EOF
+			}
+			r.pending = append(r.pending, data...)
+		}
+	}
+}
+
+// nextKey returns the length of the next key in input and the key, or 0 if
+// input doesn't hold a whole key yet
+func nextKey(input []byte) (int, string) {
+	if input[0] != 0x1b {
+		if !utf8.FullRune(input) {
+			return 0, ""
+		}
+		_, n := utf8.DecodeRune(input)
+		return n, string(input[:n])
+	}
+
+	// Escape sequences, like the arrow keys
+	if len(input) < 2 {
+		return 0, ""
+	}
+	if input[1] != '[' && input[1] != 'O' {
+		return 2, string(input[:2])
+	}
+	for i := 2; i < len(input); i++ {
+		if input[i] >= 0x40 && input[i] <= 0x7e {
+			return i + 1, string(input[:i+1])
+		}
+	}
+	return 0, ""
+}
+
+// lineEditor edits a line on a terminal in raw mode
+type lineEditor struct {
+	prompt   string
+	buf      []rune
+	complete func(string) []string
+}
+
+// handle applies a key to the line
+func (e *lineEditor) handle(key string) {
+	switch key {
+	case "\x7f", "\b": // Backspace
+		if len(e.buf) > 0 {
+			e.buf = e.buf[:len(e.buf)-1]
+			e.redraw()
+		}
+	case "\x15": // Ctrl+U
+		e.buf = nil
+		e.redraw()
+	case "\x17": // Ctrl+W
+		text := strings.TrimRight(string(e.buf), " ")
+		if i := strings.LastIndex(text, " "); i >= 0 {
+			text = text[:i+1]
+		} else {
+			text = ""
+		}
+		e.buf = []rune(text)
+		e.redraw()
+	case "\t":
+		e.completeLine()
+	default:
+		// Other control keys and escape sequences aren't supported
+		if r, _ := utf8.DecodeRuneInString(key); r < 0x20 || r == 0x1b {
+			return
+		}
+		e.buf = append(e.buf, []rune(key)...)
+		fmt.Print(key)
+	}
+}
+
+// completeLine completes the slash command being typed, listing the
+// completions when there are several
+func (e *lineEditor) completeLine() {
+	if e.complete == nil {
+		return
+	}
+	completions := e.complete(string(e.buf))
+	switch len(completions) {
+	case 0:
+		return
+	case 1:
+		e.buf = []rune(completions[0] + " ")
+	default:
+		fmt.Printf("\r\n%s%s%s\r\n", colorGray, strings.Join(completions, "  "), colorReset)
+		e.buf = []rune(commands.CommonPrefix(completions))
 	}
-	resultChan := make(chan result, 1)
+	e.redraw()
+}
+
+// redraw prints the line again
+func (e *lineEditor) redraw() {
+	fmt.Printf("\r\033[K%s%s", e.prompt, string(e.buf))
+}
 
+// interrupter cancels the request in flight on Ctrl+C, or the whole CLI when
+// there is none or when pressed again while cancelling
+type interrupter struct {
+	mu         sync.Mutex
+	cancel     context.CancelFunc // Cancels the CLI
+	request    context.CancelFunc // Cancels the request in flight, if any
+	requestCtx context.Context
+}
+
+// watch handles interrupt signals until ctx is done
+func (i *interrupter) watch(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
 	go func() {
-		text, err := reader.ReadString('\n')
-		resultChan <- result{text: text, err: err}
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigs:
+				i.interrupt()
+			}
+		}
 	}()
+}
 
-	select {
-	case <-ctx.Done():
-		return "", ctx.Err()
-	case res := <-resultChan:
-		return res.text, res.err
+func (i *interrupter) interrupt() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.request == nil {
+		i.cancel()
+		return
+	}
+	i.request()
+	i.request = nil
+	fmt.Printf("\r\033[K%s✗ Interrupted, press Ctrl+C again to exit%s\n", colorRed, colorReset)
+}
+
+// context returns the context of the request in flight, or parent if there is none
+func (i *interrupter) context(parent context.Context) context.Context {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.requestCtx != nil {
+		return i.requestCtx
 	}
+	return parent
 }
 
-func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport) error {
-	reader := bufio.NewReader(os.Stdin)
+// send sends a message to the session, cancelling it on interrupt
+func (i *interrupter) send(ctx context.Context, session *chat.Session, text string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	i.mu.Lock()
+	i.request, i.requestCtx = cancel, ctx
+	i.mu.Unlock()
+
+	_, err := session.SendMessageContext(ctx, text)
+
+	i.mu.Lock()
+	i.request, i.requestCtx = nil, nil
+	i.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// RunCLI runs the interactive CLI. If query is not empty, it is sent before prompting for input.
+func RunCLI(ctx context.Context, cfg types.Config, query string, transports ...mcp.Transport) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	interrupts := &interrupter{cancel: cancel}
+	interrupts.watch(ctx)
+
+	reader := newLineReader(interrupts.interrupt)
 	spin := newSpinner()
 
 	callbacks := chat.Callbacks{
@@ -137,15 +415,23 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 			spin.stop()
 			fmt.Println()
 			fmt.Println(strings.Repeat("─", 50))
-			fmt.Printf("%s%s🔧 Tool Request: %s%s\n", colorBold, colorYellow, req.Name, colorReset)
+			fmt.Printf("%s%s🔧 Tool Request: %s%s", colorBold, colorYellow, req.Name, colorReset)
+			if req.Server != "" {
+				fmt.Printf(" %sfrom %s%s", colorGray, req.Server, colorReset)
+			}
+			fmt.Println()
 			fmt.Printf("%sArguments:%s %s\n", colorGray, colorReset, req.Arguments)
 			if req.Reasoning != "" {
 				fmt.Printf("%s💭 %s%s\n", colorGray, req.Reasoning, colorReset)
 			}
 			fmt.Println(strings.Repeat("─", 50))
-			fmt.Printf("\n%s[y]es  [a]lways  [n]o  or type adjustment:%s ", colorCyan, colorReset)
+			fmt.Println()
 
-			text, _ := readStringCancellable(ctx, reader)
+			text, err := reader.readLine(interrupts.context(ctx), fmt.Sprintf("%s[y]es  [a]lways  [n]o  or type adjustment:%s ", colorCyan, colorReset), nil)
+			if err != nil {
+				// Interrupted, the request is being cancelled
+				return chat.ToolCallResponse{Approved: false}
+			}
 			text = strings.TrimSpace(text)
 			fmt.Println()
 
@@ -167,6 +453,11 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 			}
 			return response
 		},
+		OnToolResult: func(result chat.ToolResult) {
+			spin.stop()
+			printToolResult(result)
+			spin.start("Conjuring...")
+		},
 		OnResponse: func(response string) {
 			spin.stop()
 			fmt.Println()
@@ -179,6 +470,9 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 			spin.stop()
 			fmt.Fprintf(os.Stderr, "%s✗ Error: %v%s\n", colorRed, err, colorReset)
 		},
+		OnWarning: func(message string) {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s⚠ %s%s\n", colorYellow, message, colorReset)
+		},
 	}
 
 	session, err := chat.NewSession(ctx, cfg, callbacks, transports...)
@@ -187,19 +481,35 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 	}
 	defer session.Close()
 
+	registry := commands.Load(config.CommandDirs()...)
+	registry.AddPromptsLater(session.ListPrompts)
+
 	fmt.Printf("%s%s✨ [◠ ◠] wiz%s\n", colorBold, colorPurple, colorReset)
 	fmt.Println(strings.Repeat("─", 50))
 	fmt.Printf("%sYour terminal wizard awaits. Type your command and press Enter.%s\n", colorGray, colorReset)
-	fmt.Printf("%sCtrl+C to exit.%s\n\n", colorGray, colorReset)
+	fmt.Printf("%sType /help for commands, Ctrl+C to exit.%s\n\n", colorGray, colorReset)
+
+	if query != "" {
+		fmt.Printf("%s>%s %s\n\n", colorCyan, colorReset, query)
+		spin.start("Casting spell...")
+		err = interrupts.send(ctx, session, query)
+		spin.stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s✗ Error: %v%s\n", colorRed, err, colorReset)
+		}
+		fmt.Println()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil
 		default:
-			fmt.Printf("%s>%s ", colorCyan, colorReset)
-
-			text, err := readStringCancellable(ctx, reader)
+			text, err := reader.readLine(ctx, fmt.Sprintf("%s>%s ", colorCyan, colorReset), registry.Complete)
+			if errors.Is(err, context.Canceled) {
+				fmt.Println()
+				return nil
+			}
 			if err != nil {
 				return err
 			}
@@ -208,23 +518,31 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 				continue
 			}
 
-			switch text {
-			case "clear":
-				session.ClearHistory()
-				continue
-			case "exit":
-				return nil
-			case "help":
-				fmt.Println("Available commands:")
-				fmt.Println("  exit - Exit the wizard")
-				fmt.Println("  help - Show this help message")
-				fmt.Println("  clear - Clear the conversation")
-				continue
+			if registry.IsCommand(text) {
+				result, err := registry.Execute(commands.Env{Session: session, Config: cfg}, text)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s✗ %v%s\n\n", colorRed, err, colorReset)
+					continue
+				}
+				if result.Output != "" {
+					fmt.Printf("%s%s%s\n\n", colorGray, result.Output, colorReset)
+				}
+				if result.Input != "" && !reader.prefill(result.Input) {
+					// Without a terminal, the line can't be edited: show the question to type it again
+					fmt.Printf("%sLast question removed, type the corrected one:%s\n%s\n\n", colorGray, colorReset, result.Input)
+				}
+				if result.Exit {
+					return nil
+				}
+				if result.Send == "" {
+					continue
+				}
+				text = result.Send
 			}
 
 			fmt.Println()
 			spin.start("Casting spell...")
-			_, err = session.SendMessage(text)
+			err = interrupts.send(ctx, session, text)
 			spin.stop()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s✗ Error: %v%s\n", colorRed, err, colorReset)
@@ -233,3 +551,31 @@ func RunCLI(ctx context.Context, cfg types.Config, transports ...mcp.Transport)
 		}
 	}
 }
+
+// printToolResult prints the outcome of a tool run by the agent
+func printToolResult(result chat.ToolResult) {
+	if !result.IsCommand() {
+		fmt.Printf("%s🔧 %s%s\n", colorYellow, result.Name, colorReset)
+		if output := strings.TrimSpace(result.Output); output != "" {
+			fmt.Printf("%s%s%s\n", colorGray, chat.TrimLines(output, maxToolOutputLines), colorReset)
+		}
+		fmt.Println()
+		return
+	}
+
+	exitColor := colorGreen
+	if result.Failed() {
+		exitColor = colorRed
+	}
+	fmt.Printf("%s🔧 %s%s %s[exit %d]%s\n", colorYellow, result.Name, colorReset, exitColor, *result.ExitCode, colorReset)
+	if stdout := strings.TrimSpace(result.Stdout); stdout != "" {
+		fmt.Println(chat.TrimLines(stdout, maxToolOutputLines))
+	}
+	if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+		fmt.Printf("%s%s%s\n", colorRed, chat.TrimLines(stderr, maxToolOutputLines), colorReset)
+	}
+	if result.Error != "" && result.Stderr == "" {
+		fmt.Printf("%s%s%s\n", colorRed, result.Error, colorReset)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/mudler/wiz/tui"
+)
+
+// CursorUnits are the units shells give the cursor position in, for --cursor-unit
+var CursorUnits = []string{"rune", "byte", "utf16"}
+
+// CursorIndex converts a cursor position given by a shell in unit into an
+// index in the runes of buffer: bash and elvish count bytes, PowerShell UTF-16
+// code units and the other shells characters. A negative cursor means the end
+// of the line and is kept as is.
+func CursorIndex(buffer string, cursor int, unit string) (int, error) {
+	if cursor < 0 {
+		return cursor, nil
+	}
+
+	switch unit {
+	case "", "rune":
+		return cursor, nil
+	case "byte":
+		if cursor > len(buffer) {
+			cursor = len(buffer)
+		}
+		return utf8.RuneCountInString(buffer[:cursor]), nil
+	case "utf16":
+		units := 0
+		for i, r := range []rune(buffer) {
+			if units >= cursor {
+				return i, nil
+			}
+			units += utf16.RuneLen(r)
+		}
+		return utf8.RuneCountInString(buffer), nil
+	default:
+		return 0, fmt.Errorf("unknown cursor unit %q, expected one of %v", unit, CursorUnits)
+	}
+}
+
+// MergeCommandLine returns the command line the shell widget should show once
+// wiz returns command. Depending on how the command was used, it either
+// replaces what was being typed (e.g. "tar" becoming "tar -xf a.tar -C /tmp")
+// or is inserted at the cursor position, given in runes. A negative cursor
+// means the end of the line.
+func MergeCommandLine(buffer string, cursor int, command string, mode tui.OutputMode) string {
+	if command == "" {
+		return buffer
+	}
+	if mode == tui.ReplaceCommandLine {
+		return command
+	}
+
+	runes := []rune(buffer)
+	if cursor < 0 || cursor > len(runes) {
+		cursor = len(runes)
+	}
+	return string(runes[:cursor]) + command + string(runes[cursor:])
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/mudler/wiz/tui"
+)
+
+func TestCursorIndex(t *testing.T) {
+	// "é" is 2 bytes and 1 UTF-16 unit, "🙂" 4 bytes and 2 UTF-16 units
+	buffer := "é🙂 ls"
+	tests := []struct {
+		unit   string
+		cursor int
+		want   int
+	}{
+		{"rune", 3, 3},
+		{"", 3, 3},
+		{"byte", 0, 0},
+		{"byte", 2, 1},
+		{"byte", 6, 2},
+		{"byte", 9, 5},
+		{"byte", 100, 5},
+		{"utf16", 1, 1},
+		{"utf16", 3, 2},
+		{"utf16", 6, 5},
+		{"utf16", 100, 5},
+		{"byte", -1, -1},
+	}
+
+	for _, test := range tests {
+		got, err := CursorIndex(buffer, test.cursor, test.unit)
+		if err != nil {
+			t.Fatalf("CursorIndex(%q, %d): %v", test.unit, test.cursor, err)
+		}
+		if got != test.want {
+			t.Errorf("CursorIndex(%q, %d) = %d, want %d", test.unit, test.cursor, got, test.want)
+		}
+	}
+
+	if _, err := CursorIndex(buffer, 1, "column"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
+
+func TestMergeCommandLine(t *testing.T) {
+	tests := map[string]struct {
+		buffer  string
+		cursor  int
+		command string
+		mode    tui.OutputMode
+		want    string
+	}{
+		"replace":            {"tar", 3, "tar -xf a.tar -C /tmp", tui.ReplaceCommandLine, "tar -xf a.tar -C /tmp"},
+		"replace unrelated":  {"cd ", 3, "git status", tui.ReplaceCommandLine, "git status"},
+		"insert at cursor":   {"cd  && ls", 3, "$(git rev-parse --show-toplevel)", tui.InsertCommand, "cd $(git rev-parse --show-toplevel) && ls"},
+		"insert after runes": {"échø  ok", 5, "hi", tui.InsertCommand, "échø hi ok"},
+		"insert at end":      {"ls ", -1, "-la", tui.InsertCommand, "ls -la"},
+		"insert in empty":    {"", -1, "ls", tui.InsertCommand, "ls"},
+		"nothing returned":   {"ls", 2, "", tui.ReplaceCommandLine, "ls"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := MergeCommandLine(test.buffer, test.cursor, test.command, test.mode); got != test.want {
+				t.Fatalf("MergeCommandLine = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
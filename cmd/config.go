@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mudler/wiz/config"
+	"github.com/mudler/wiz/types"
+	"gopkg.in/yaml.v3"
+)
+
+// RunConfig implements the 'wiz config' commands
+func RunConfig(cfg types.Config, origins config.Origins, problems config.Problems, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: wiz config show [--origin] | validate | trust | untrust")
+	}
+
+	switch args[0] {
+	case "show":
+		return showConfig(cfg, origins, args[1:])
+	case "validate":
+		return validateConfig(problems)
+	case "trust", "untrust":
+		trusted := args[0] == "trust"
+		project, err := config.TrustProject(trusted)
+		if err != nil {
+			return err
+		}
+		if trusted {
+			fmt.Printf("Trusting %s as it is now\n", strings.Join(project.Files(), " and "))
+		} else {
+			fmt.Printf("Ignoring %s\n", strings.Join(project.Files(), " and "))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown config command %q, expected show, validate, trust or untrust", args[0])
+	}
+}
+
+// validateConfig prints the problems found in the config, and fails if wiz
+// can't run with it
+func validateConfig(problems config.Problems) error {
+	for _, problem := range problems {
+		level := "error"
+		if problem.Warning {
+			level = "warning"
+		}
+		fmt.Printf("%s: %s\n", level, problem)
+	}
+
+	if errs := problems.Errors(); len(errs) > 0 {
+		return fmt.Errorf("found %d errors in the configuration", len(errs))
+	}
+	if len(problems) == 0 {
+		fmt.Println("Configuration is valid")
+	}
+	return nil
+}
+
+// showConfig prints the effective config, secrets redacted, optionally with
+// where each value comes from
+func showConfig(cfg types.Config, origins config.Origins, args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	origin := fs.Bool("origin", false, "Show where each value comes from")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: wiz config show [flags]\n\nPrints the configuration wiz runs with, after merging the config files, the environment and --set flags.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return err
+	}
+	annotate(&doc, "", cfg.Redact, origins, *origin)
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&doc)
+}
+
+// annotate redacts the values of node, and comments them with their origin
+func annotate(node *yaml.Node, path string, redact func(string) string, origins config.Origins, withOrigin bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			annotate(value, keyPath, redact, origins, withOrigin)
+			if !withOrigin {
+				continue
+			}
+			if len(value.Content) == 0 && value.Kind != yaml.ScalarNode {
+				// Empty collections are written inline, after the key
+				value.LineComment = origins.Of(keyPath)
+			} else if value.Kind != yaml.MappingNode {
+				key.LineComment = origins.Of(keyPath)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			annotate(item, path, redact, origins, false)
+		}
+	case yaml.ScalarNode:
+		node.Value = redact(node.Value)
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mudler/wiz/chat"
+)
+
+// RunExport implements 'wiz export': it converts a file written by
+// '/export json' to another format. Sessions aren't kept once wiz exits, so
+// that file is the only way to get a conversation back.
+func RunExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "markdown", "Export format ("+strings.Join(chat.ExportFormats, ", ")+")")
+	output := fs.String("output", "", "Write to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: wiz export [flags] <file.json>\n\n"+
+			"Converts a conversation saved with '/export json' to another format.\n"+
+			"wiz doesn't keep sessions once it exits: save the conversation with\n"+
+			"'/export json' first. Markdown and shell exports can't be converted back.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a file written by '/export json'")
+	}
+
+	conversation, err := chat.ReadConversation(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	content, err := conversation.Format(*format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Print(content)
+		return nil
+	}
+	return os.WriteFile(*output, []byte(content), 0600)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxStderrBytes bounds how much of the captured error output is sent to the model
+const maxStderrBytes = 4096
+
+// FixQuery builds the question asked in --fix mode from the last failed
+// command recorded by the shell integration hooks
+func FixQuery() (string, error) {
+	command := os.Getenv("WIZ_LAST_COMMAND")
+	if command == "" {
+		return "", errors.New("no failed command recorded, make sure the shell integration is loaded (wiz --init <shell>)")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("My last shell command failed.\n\n")
+	sb.WriteString(fmt.Sprintf("Command: %s\n", command))
+	if status := os.Getenv("WIZ_LAST_STATUS"); status != "" {
+		sb.WriteString(fmt.Sprintf("Exit status: %s\n", status))
+	}
+	if stderr := lastStderr(); stderr != "" {
+		sb.WriteString(fmt.Sprintf("Error output:\n```\n%s\n```\n", stderr))
+	}
+	sb.WriteString("\nExplain briefly what went wrong and propose a corrected command.")
+
+	return sb.String(), nil
+}
+
+// lastStderr returns the error output captured for the last failed command, if any
+func lastStderr() string {
+	path := os.Getenv("WIZ_LAST_STDERR")
+	if path == "" {
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	// Keep the end of the output, where the actual error usually is
+	if len(data) > maxStderrBytes {
+		data = data[len(data)-maxStderrBytes:]
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ExplainQuery builds the question asked in --explain mode about the command line being edited
+func ExplainQuery(commandLine string) (string, error) {
+	if strings.TrimSpace(commandLine) == "" {
+		return "", errors.New("nothing to explain, the command line is empty")
+	}
+
+	return fmt.Sprintf("Explain what this command does, part by part:\n\n```\n%s\n```", commandLine), nil
+}
@@ -1,90 +1,531 @@
 package cmd
 
-// getInitScript returns the shell integration script for the given shell
-func GetInitScript(shell string) string {
-	switch shell {
-	case "zsh":
-		return zshInitScript
-	case "bash":
-		return bashInitScript
-	case "fish":
-		return fishInitScript
-	default:
-		return ""
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mudler/wiz/types"
+)
+
+// SupportedShells lists the shells GetInitScript has an integration for
+var SupportedShells = []string{"zsh", "bash", "fish", "nu", "pwsh", "elvish", "xonsh"}
+
+// shellIntegration describes how to generate the init script of a shell
+type shellIntegration struct {
+	script string
+	// keys translates a key binding to the shell's syntax
+	keys func([]key) (string, error)
+	// defaults are the key bindings used when none is configured
+	defaults types.KeyBindings
+}
+
+// defaultShellKeys are the widget key bindings of shells supporting key sequences
+var defaultShellKeys = types.KeyBindings{Ask: "ctrl+space", Fix: "ctrl+x f", Explain: "ctrl+x e"}
+
+// singleShellKeys are the widget key bindings of shells that only bind single keys
+var singleShellKeys = types.KeyBindings{Ask: "ctrl+space", Fix: "alt+x", Explain: "alt+e"}
+
+var shellIntegrations = map[string]shellIntegration{
+	"zsh":    {script: zshInitScript, keys: zshKeys, defaults: defaultShellKeys},
+	"bash":   {script: bashInitScript, keys: bashKeys, defaults: defaultShellKeys},
+	"fish":   {script: fishInitScript, keys: fishKeys, defaults: defaultShellKeys},
+	"nu":     {script: nuInitScript, keys: nuKeys, defaults: singleShellKeys},
+	"pwsh":   {script: pwshInitScript, keys: pwshKeys, defaults: defaultShellKeys},
+	"elvish": {script: elvishInitScript, keys: elvishKeys, defaults: singleShellKeys},
+	"xonsh":  {script: xonshInitScript, keys: xonshKeys, defaults: defaultShellKeys},
+}
+
+// shellAliases maps alternative shell names to the supported ones
+var shellAliases = map[string]string{
+	"nushell":    "nu",
+	"powershell": "pwsh",
+}
+
+// getInitScript returns the shell integration script for the given shell,
+// with the widgets bound to the configured keys
+func GetInitScript(shell string, keys types.KeyBindings) (string, error) {
+	if alias, ok := shellAliases[shell]; ok {
+		shell = alias
+	}
+	integration, ok := shellIntegrations[shell]
+	if !ok {
+		return "", fmt.Errorf("unknown shell: %s. Supported: %s", shell, strings.Join(SupportedShells, ", "))
+	}
+
+	bindings := map[string]string{
+		"Ask":     orDefault(keys.Ask, integration.defaults.Ask),
+		"Fix":     orDefault(keys.Fix, integration.defaults.Fix),
+		"Explain": orDefault(keys.Explain, integration.defaults.Explain),
+	}
+	for name, spec := range bindings {
+		parsed, err := parseKeys(spec)
+		if err != nil {
+			return "", err
+		}
+		translated, err := integration.keys(parsed)
+		if err != nil {
+			return "", fmt.Errorf("key binding %q: %w", spec, err)
+		}
+		bindings[name] = translated
 	}
+
+	tmpl, err := template.New(shell).Parse(integration.script)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, bindings); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// orDefault returns value, or def if value is empty
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
 }
 
 const zshInitScript = `# wiz shell integration for zsh
 # Add this to your ~/.zshrc:
 #   eval "$(wiz --init zsh)"
+#
+# Set WIZ_CAPTURE_STDERR=1 before loading it to also record the error
+# output of failed commands for 'wiz --fix'.
 
-__wiz_widget() {
+__wiz_run() {
   local output
-  # Save the current buffer
-  local saved_buffer="$BUFFER"
-  local saved_cursor="$CURSOR"
   
   # Summon the wizard in TUI mode
   # Uses tmux popup when in tmux, otherwise uses alt screen
   # The TUI writes to /dev/tty directly, stdout captures only the final output
-  output=$(wiz --height 50%)
+  output=$(wiz --shell zsh --height 50% "$@")
   local ret=$?
   
-  # If wiz output a command, insert it
+  # If wiz output a command, it is the new command line
   if [[ -n "$output" ]]; then
-    BUFFER="${saved_buffer:0:$saved_cursor}${output}${saved_buffer:$saved_cursor}"
-    CURSOR=$((saved_cursor + ${#output}))
+    BUFFER="$output"
+    CURSOR=${#BUFFER}
   fi
   
   zle reset-prompt
   return $ret
 }
 
+__wiz_widget() {
+  # Pass the command line being edited
+  __wiz_run --buffer "$BUFFER" --cursor "$CURSOR"
+}
+
+__wiz_fix_widget() {
+  # Explain and fix the last failed command, keeping the command line being edited
+  __wiz_run --fix --buffer "$BUFFER" --cursor "$CURSOR"
+}
+
+__wiz_explain_widget() {
+  # Explain the command line being edited
+  __wiz_run --explain --buffer "$BUFFER" --cursor "$CURSOR"
+}
+
+# Record the last failed command for 'wiz --fix'. The error output is kept
+# in a private directory, created once per shell and removed when it exits.
+__wiz_preexec() {
+  __wiz_command="$1"
+  if [[ -n "$WIZ_CAPTURE_STDERR" ]]; then
+    if [[ -z "$__wiz_stderr_dir" ]]; then
+      __wiz_stderr_dir=$(command mktemp -d "${TMPDIR:-/tmp}/wiz.XXXXXXXX") || return
+    fi
+    exec {__wiz_stderr_fd}>&2 2> >(tee "$__wiz_stderr_dir/stderr" >&2)
+  fi
+}
+
+__wiz_precmd() {
+  local ret=$?
+  if [[ -n "$__wiz_stderr_fd" ]]; then
+    exec 2>&$__wiz_stderr_fd {__wiz_stderr_fd}>&-
+    unset __wiz_stderr_fd
+  fi
+  if [[ -n "$__wiz_command" && $ret -ne 0 ]]; then
+    export WIZ_LAST_COMMAND="$__wiz_command" WIZ_LAST_STATUS=$ret
+    if [[ -n "$__wiz_stderr_dir" ]]; then
+      command cp -f "$__wiz_stderr_dir/stderr" "$__wiz_stderr_dir/last" 2>/dev/null
+      export WIZ_LAST_STDERR="$__wiz_stderr_dir/last"
+    fi
+  elif [[ -n "$__wiz_command" ]]; then
+    # The last command succeeded, there is nothing to fix
+    unset WIZ_LAST_COMMAND WIZ_LAST_STATUS WIZ_LAST_STDERR
+  fi
+  unset __wiz_command
+}
+
+__wiz_zshexit() {
+  [[ -n "$__wiz_stderr_dir" ]] && command rm -rf -- "$__wiz_stderr_dir"
+}
+
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec __wiz_preexec
+add-zsh-hook precmd __wiz_precmd
+add-zsh-hook zshexit __wiz_zshexit
+
 zle -N __wiz_widget
-bindkey '^ ' __wiz_widget  # Ctrl+Space
+zle -N __wiz_fix_widget
+zle -N __wiz_explain_widget
+bindkey '{{.Ask}}' __wiz_widget
+bindkey '{{.Fix}}' __wiz_fix_widget
+bindkey '{{.Explain}}' __wiz_explain_widget
 `
 
 const bashInitScript = `# wiz shell integration for bash
 # Add this to your ~/.bashrc:
 #   eval "$(wiz --init bash)"
 
-__wiz_widget() {
+__wiz_run() {
   local output
-  local saved_line="$READLINE_LINE"
-  local saved_point="$READLINE_POINT"
   
   # Summon the wizard in TUI mode
   # Uses tmux popup when in tmux, otherwise uses alt screen
   # The TUI writes to /dev/tty directly, stdout captures only the final output
-  output=$(wiz --height 50%)
+  output=$(wiz --shell bash --height 50% "$@")
   
-  # If wiz output a command, insert it
+  # If wiz output a command, it is the new command line
   if [[ -n "$output" ]]; then
-    READLINE_LINE="${saved_line:0:$saved_point}${output}${saved_line:$saved_point}"
-    READLINE_POINT=$((saved_point + ${#output}))
+    READLINE_LINE="$output"
+    # READLINE_POINT counts bytes
+    local LC_ALL=C
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+__wiz_widget() {
+  # Pass the command line being edited
+  __wiz_run --buffer "$READLINE_LINE" --cursor "$READLINE_POINT" --cursor-unit byte
+}
+
+__wiz_fix_widget() {
+  # Explain and fix the last failed command, keeping the command line being edited
+  __wiz_run --fix --buffer "$READLINE_LINE" --cursor "$READLINE_POINT" --cursor-unit byte
+}
+
+__wiz_explain_widget() {
+  # Explain the command line being edited
+  __wiz_run --explain --buffer "$READLINE_LINE" --cursor "$READLINE_POINT" --cursor-unit byte
+}
+
+# Record the last failed command for 'wiz --fix': the whole line, pipelines
+# and lists included, as the history has it. A line that didn't make it to
+# the history (e.g. with HISTCONTROL=ignorespace) leaves the number unchanged.
+__wiz_history_entry() {
+  local entry
+  entry=$(HISTTIMEFORMAT= builtin history 1)
+  [[ $entry =~ ^[[:space:]]*([0-9]+)\*?[[:space:]]+(.*)$ ]]
+}
+
+__wiz_prompt_command() {
+  local ret=$?
+  if [[ $ret -eq 0 ]]; then
+    # The last command succeeded, there is nothing to fix
+    unset WIZ_LAST_COMMAND WIZ_LAST_STATUS
+  elif __wiz_history_entry && [[ "${BASH_REMATCH[1]}" != "$__wiz_history_number" ]]; then
+    export WIZ_LAST_COMMAND="${BASH_REMATCH[2]}" WIZ_LAST_STATUS=$ret
   fi
+  __wiz_history_entry && __wiz_history_number=${BASH_REMATCH[1]}
+  return $ret
 }
 
-# Bind Ctrl+Space
-bind -x '"\C- ": __wiz_widget'
+# Commands already in the history when the shell starts aren't reported
+__wiz_history_entry && __wiz_history_number=${BASH_REMATCH[1]}
+
+if [[ ";${PROMPT_COMMAND[*]};" != *";__wiz_prompt_command;"* ]]; then
+  PROMPT_COMMAND="__wiz_prompt_command${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+
+bind -x '"{{.Ask}}": __wiz_widget'
+bind -x '"{{.Fix}}": __wiz_fix_widget'
+bind -x '"{{.Explain}}": __wiz_explain_widget'
 `
 
 const fishInitScript = `# wiz shell integration for fish
 # Add this to your ~/.config/fish/config.fish:
 #   wiz --init fish | source
 
-function __wiz_widget
+function __wiz_run
   # Summon the wizard in TUI mode
   # Uses tmux popup when in tmux, otherwise uses alt screen
   # The TUI writes to /dev/tty directly, stdout captures only the final output
-  set -l output (wiz --height 50%)
+  set -l output (wiz --shell fish --height 50% $argv | string collect)
   
+  # If wiz output a command, it is the new command line
   if test -n "$output"
-    commandline -i "$output"
+    commandline -r -- $output
+    commandline -f end-of-line
   end
   
   commandline -f repaint
 end
 
-bind \c\  __wiz_widget  # Ctrl+Space
+function __wiz_widget
+  # Pass the command line being edited
+  __wiz_run --buffer=(commandline | string collect) --cursor=(commandline -C)
+end
+
+function __wiz_fix_widget
+  # Explain and fix the last failed command, keeping the command line being edited
+  __wiz_run --fix --buffer=(commandline | string collect) --cursor=(commandline -C)
+end
+
+function __wiz_explain_widget
+  # Explain the command line being edited
+  __wiz_run --explain --buffer=(commandline | string collect) --cursor=(commandline -C)
+end
+
+# Record the last failed command for 'wiz --fix'
+function __wiz_postexec --on-event fish_postexec
+  set -l ret $status
+  if test $ret -ne 0
+    set -gx WIZ_LAST_COMMAND $argv[1]
+    set -gx WIZ_LAST_STATUS $ret
+  else
+    # The last command succeeded, there is nothing to fix
+    set -e WIZ_LAST_COMMAND WIZ_LAST_STATUS
+  end
+end
+
+bind {{.Ask}} __wiz_widget
+bind {{.Fix}} __wiz_fix_widget
+bind {{.Explain}} __wiz_explain_widget
+`
+
+const nuInitScript = `# wiz shell integration for nushell
+# Add this to your config.nu:
+#   wiz --init nu | save -f ($nu.default-config-dir | path join wiz.nu)
+#   source wiz.nu
+
+def --env __wiz_run [...args: string] {
+  # Summon the wizard in TUI mode
+  # The TUI writes to /dev/tty directly, stdout captures only the final output
+  let output = (^wiz --shell nu --height 50% ...$args | str trim --right)
+
+  # If wiz output a command, it is the new command line
+  if ($output | is-not-empty) {
+    commandline edit --replace $output
+    commandline set-cursor --end
+  }
+}
+
+# Record the last failed command for 'wiz --fix'
+$env.config = ($env.config | upsert hooks.pre_execution (
+  ($env.config.hooks.pre_execution? | default []) | append {||
+    $env.__WIZ_COMMAND = (commandline)
+  }
+))
+$env.config = ($env.config | upsert hooks.pre_prompt (
+  ($env.config.hooks.pre_prompt? | default []) | append {||
+    if ($env.__WIZ_COMMAND? | is-not-empty) {
+      if ($env.LAST_EXIT_CODE != 0) {
+        $env.WIZ_LAST_COMMAND = $env.__WIZ_COMMAND
+        $env.WIZ_LAST_STATUS = ($env.LAST_EXIT_CODE | into string)
+      } else {
+        # The last command succeeded, there is nothing to fix
+        hide-env -i WIZ_LAST_COMMAND WIZ_LAST_STATUS
+      }
+    }
+    $env.__WIZ_COMMAND = ""
+  }
+))
+
+$env.config = ($env.config | upsert keybindings (
+  $env.config.keybindings | append [
+    {
+      name: wiz
+      {{.Ask}}
+      mode: [emacs vi_normal vi_insert]
+      event: {
+        send: executehostcommand
+        cmd: "__wiz_run $'--buffer=(commandline)' $'--cursor=(commandline get-cursor)'"
+      }
+    }
+    {
+      name: wiz_fix
+      {{.Fix}}
+      mode: [emacs vi_normal vi_insert]
+      event: {
+        send: executehostcommand
+        cmd: "__wiz_run --fix $'--buffer=(commandline)' $'--cursor=(commandline get-cursor)'"
+      }
+    }
+    {
+      name: wiz_explain
+      {{.Explain}}
+      mode: [emacs vi_normal vi_insert]
+      event: {
+        send: executehostcommand
+        cmd: "__wiz_run --explain $'--buffer=(commandline)' $'--cursor=(commandline get-cursor)'"
+      }
+    }
+  ]
+))
+`
+
+const pwshInitScript = `# wiz shell integration for PowerShell
+# Add this to your $PROFILE:
+#   Invoke-Expression (& wiz --init pwsh | Out-String)
+
+function global:__wiz_run {
+  param([string[]]$WizArgs)
+
+  # Summon the wizard in TUI mode
+  # The TUI writes to /dev/tty directly, stdout captures only the final output
+  $output = (& wiz --shell pwsh --height 50% @WizArgs) -join [Environment]::NewLine
+
+  # If wiz output a command, it is the new command line
+  if ($output) {
+    $line = $null
+    $cursor = $null
+    [Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+    [Microsoft.PowerShell.PSConsoleReadLine]::Replace(0, $line.Length, $output)
+    [Microsoft.PowerShell.PSConsoleReadLine]::SetCursorPosition($output.Length)
+  }
+
+  [Microsoft.PowerShell.PSConsoleReadLine]::InvokePrompt()
+}
+
+# Record the last failed command for 'wiz --fix'
+$global:__wiz_original_prompt = $function:prompt
+$global:__wiz_last_history_id = 0
+
+function global:prompt {
+  $succeeded = $?
+  $exitCode = $global:LASTEXITCODE
+
+  $last = Get-History -Count 1
+  if ($last -and $last.Id -ne $global:__wiz_last_history_id) {
+    $global:__wiz_last_history_id = $last.Id
+    if (-not $succeeded) {
+      $env:WIZ_LAST_COMMAND = $last.CommandLine
+      $env:WIZ_LAST_STATUS = if ($exitCode) { "$exitCode" } else { "1" }
+    } else {
+      # The last command succeeded, there is nothing to fix
+      Remove-Item Env:WIZ_LAST_COMMAND, Env:WIZ_LAST_STATUS -ErrorAction SilentlyContinue
+    }
+  }
+
+  $global:LASTEXITCODE = $exitCode
+  & $global:__wiz_original_prompt
+}
+
+Set-PSReadLineKeyHandler -Chord '{{.Ask}}' -BriefDescription 'wiz' -ScriptBlock {
+  $line = $null
+  $cursor = $null
+  [Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+  __wiz_run @("--buffer=$line", "--cursor=$cursor", "--cursor-unit=utf16")
+}
+
+Set-PSReadLineKeyHandler -Chord '{{.Fix}}' -BriefDescription 'wiz fix' -ScriptBlock {
+  $line = $null
+  $cursor = $null
+  [Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+  __wiz_run @('--fix', "--buffer=$line", "--cursor=$cursor", "--cursor-unit=utf16")
+}
+
+Set-PSReadLineKeyHandler -Chord '{{.Explain}}' -BriefDescription 'wiz explain' -ScriptBlock {
+  $line = $null
+  $cursor = $null
+  [Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+  __wiz_run @('--explain', "--buffer=$line", "--cursor=$cursor", "--cursor-unit=utf16")
+}
+`
+
+const elvishInitScript = `# wiz shell integration for elvish
+# Add this to your ~/.config/elvish/rc.elv:
+#   eval (wiz --init elvish | slurp)
+
+use str
+
+fn __wiz_run {|@args|
+  # Summon the wizard in TUI mode
+  # The TUI writes to /dev/tty directly, stdout captures only the final output
+  var output = (wiz --shell elvish --height 50% $@args | slurp)
+  set output = (str:trim-right $output "\n")
+
+  # If wiz output a command, it is the new command line
+  if (!=s $output '') {
+    set edit:current-command = $output
+    # The cursor position counts bytes
+    set edit:-dot = (str:to-utf8-bytes $output | count)
+  }
+  edit:redraw &full=$true
+}
+
+# Record the last failed command for 'wiz --fix'
+set edit:after-command = [$@edit:after-command {|m|
+  if (not-eq $m[error] $nil) {
+    set-env WIZ_LAST_COMMAND $m[src][code]
+    set-env WIZ_LAST_STATUS (try { to-string $m[error][reason][exit-status] } catch { put 1 })
+  } else {
+    # The last command succeeded, there is nothing to fix
+    unset-env WIZ_LAST_COMMAND
+    unset-env WIZ_LAST_STATUS
+  }
+}]
+
+set edit:insert:binding[{{.Ask}}] = { __wiz_run '--buffer='$edit:current-command '--cursor='$edit:-dot --cursor-unit=byte }
+set edit:insert:binding[{{.Fix}}] = { __wiz_run --fix '--buffer='$edit:current-command '--cursor='$edit:-dot --cursor-unit=byte }
+set edit:insert:binding[{{.Explain}}] = { __wiz_run --explain '--buffer='$edit:current-command '--cursor='$edit:-dot --cursor-unit=byte }
+`
+
+const xonshInitScript = `# wiz shell integration for xonsh
+# Add this to your ~/.xonshrc:
+#   execx($(wiz --init xonsh))
+
+import subprocess as _wiz_subprocess
+from prompt_toolkit.application import run_in_terminal as _wiz_run_in_terminal
+
+
+def _wiz_run(buffer, *args):
+    # Summon the wizard in TUI mode
+    # The TUI writes to /dev/tty directly, stdout captures only the final output
+    def run():
+        result = _wiz_subprocess.run(["wiz", "--shell", "xonsh", "--height", "50%", *args], stdout=_wiz_subprocess.PIPE, text=True)
+        output = result.stdout.rstrip("\n")
+
+        # If wiz output a command, it is the new command line
+        if output:
+            buffer.text = output
+            buffer.cursor_position = len(output)
+
+    _wiz_run_in_terminal(run)
+
+
+# Record the last failed command for 'wiz --fix'
+@events.on_postcommand
+def _wiz_postcommand(cmd, rtn, out, ts, **kwargs):
+    if rtn != 0:
+        $WIZ_LAST_COMMAND = cmd.strip()
+        $WIZ_LAST_STATUS = str(rtn)
+    else:
+        # The last command succeeded, there is nothing to fix
+        ${...}.pop("WIZ_LAST_COMMAND", None)
+        ${...}.pop("WIZ_LAST_STATUS", None)
+
+
+@events.on_ptk_create
+def _wiz_bindings(prompter, history, completer, bindings, **kwargs):
+    @bindings.add({{.Ask}})
+    def _wiz_widget(event):
+        buffer = event.current_buffer
+        _wiz_run(buffer, "--buffer=" + buffer.text, "--cursor=" + str(buffer.cursor_position))
+
+    @bindings.add({{.Fix}})
+    def _wiz_fix_widget(event):
+        buffer = event.current_buffer
+        _wiz_run(buffer, "--fix", "--buffer=" + buffer.text, "--cursor=" + str(buffer.cursor_position))
+
+    @bindings.add({{.Explain}})
+    def _wiz_explain_widget(event):
+        buffer = event.current_buffer
+        _wiz_run(buffer, "--explain", "--buffer=" + buffer.text, "--cursor=" + str(buffer.cursor_position))
 `
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mudler/wiz/types"
+)
+
+// syntaxChecks are the commands checking a script's syntax without running
+// it, by shell. The script's path is appended to the arguments, or replaces
+// {} in them.
+var syntaxChecks = map[string][]string{
+	"zsh":  {"zsh", "-n"},
+	"bash": {"bash", "-n"},
+	"fish": {"fish", "-n"},
+	"nu":   {"nu", "--ide-check", "10"},
+	"pwsh": {"pwsh", "-NoProfile", "-NonInteractive", "-Command", `$errors = $null; ` +
+		`[System.Management.Automation.Language.Parser]::ParseFile('{}', [ref]$null, [ref]$errors) | Out-Null; ` +
+		`if ($errors) { $errors | ForEach-Object { $_.ToString() }; exit 1 }`},
+	"elvish": {"elvish", "-compileonly"},
+	"xonsh":  {"xonsh", "--no-rc", "-c", "compilex(open('{}').read())"},
+}
+
+// syntaxCheckKeys are the key bindings the scripts are checked with
+var syntaxCheckKeys = map[string]types.KeyBindings{
+	"default": {},
+	"custom":  {Ask: "ctrl+g", Fix: "alt+f", Explain: "alt+e"},
+}
+
+func TestInitScriptsSyntax(t *testing.T) {
+	for _, shell := range SupportedShells {
+		for name, keys := range syntaxCheckKeys {
+			t.Run(shell+"/"+name, func(t *testing.T) {
+				check, ok := syntaxChecks[shell]
+				if !ok {
+					t.Fatalf("no syntax check for %s", shell)
+				}
+				if _, err := exec.LookPath(check[0]); err != nil {
+					t.Skipf("%s is not installed", check[0])
+				}
+
+				script, err := GetInitScript(shell, keys)
+				if err != nil {
+					t.Fatalf("GetInitScript(%q): %v", shell, err)
+				}
+				path := filepath.Join(t.TempDir(), "wiz."+shell)
+				if err := os.WriteFile(path, []byte(script), 0600); err != nil {
+					t.Fatal(err)
+				}
+
+				args := []string{}
+				replaced := false
+				for _, arg := range check[1:] {
+					if strings.Contains(arg, "{}") {
+						arg, replaced = strings.ReplaceAll(arg, "{}", path), true
+					}
+					args = append(args, arg)
+				}
+				if !replaced {
+					args = append(args, path)
+				}
+
+				cmd := exec.Command(check[0], args...)
+				cmd.Dir = t.TempDir()
+				cmd.Env = append(os.Environ(), "HOME="+t.TempDir())
+				out, err := cmd.CombinedOutput()
+				if err != nil {
+					t.Fatalf("%s rejected the script: %v\n%s", check[0], err, out)
+				}
+				// nushell reports the errors as JSON diagnostics
+				if shell == "nu" && strings.Contains(string(out), `"severity":"Error"`) {
+					t.Fatalf("nu rejected the script:\n%s", out)
+				}
+			})
+		}
+	}
+}
+
+func TestInitScriptUnknownShell(t *testing.T) {
+	if _, err := GetInitScript("tcsh", types.KeyBindings{}); err == nil {
+		t.Fatal("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// key is a single key press of a key binding, e.g. "ctrl+x"
+type key struct {
+	ctrl bool
+	alt  bool
+	name string // A single character, or "space"
+}
+
+// parseKeys parses a key binding like "ctrl+space" or "ctrl+x f" into its key presses
+func parseKeys(spec string) ([]key, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key binding")
+	}
+
+	keys := []key{}
+	for _, field := range fields {
+		parts := strings.Split(field, "+")
+
+		k := key{name: parts[len(parts)-1]}
+		for _, mod := range parts[:len(parts)-1] {
+			switch mod {
+			case "ctrl", "control":
+				k.ctrl = true
+			case "alt", "meta":
+				k.alt = true
+			default:
+				return nil, fmt.Errorf("invalid key binding %q: unknown modifier %q", spec, mod)
+			}
+		}
+
+		if k.name != "space" && len([]rune(k.name)) != 1 {
+			return nil, fmt.Errorf("invalid key binding %q: unknown key %q", spec, k.name)
+		}
+		if k.ctrl && k.alt {
+			return nil, fmt.Errorf("invalid key binding %q: ctrl and alt can't be combined", spec)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// char returns the character typed by the key
+func (k key) char() string {
+	if k.name == "space" {
+		return " "
+	}
+	return k.name
+}
+
+// zshKeys translates a key binding for zsh's bindkey
+func zshKeys(keys []key) (string, error) {
+	var sb strings.Builder
+	for _, k := range keys {
+		switch {
+		case k.ctrl:
+			sb.WriteString("^" + strings.ToUpper(k.char()))
+		case k.alt:
+			sb.WriteString("^[" + k.char())
+		default:
+			sb.WriteString(k.char())
+		}
+	}
+	return sb.String(), nil
+}
+
+// bashKeys translates a key binding for readline's bind
+func bashKeys(keys []key) (string, error) {
+	var sb strings.Builder
+	for _, k := range keys {
+		switch {
+		case k.ctrl:
+			sb.WriteString(`\C-` + k.char())
+		case k.alt:
+			sb.WriteString(`\e` + k.char())
+		default:
+			sb.WriteString(k.char())
+		}
+	}
+	return sb.String(), nil
+}
+
+// fishKeys translates a key binding for fish's bind
+func fishKeys(keys []key) (string, error) {
+	var sb strings.Builder
+	for _, k := range keys {
+		c := k.char()
+		if c == " " {
+			c = `\ `
+		}
+		switch {
+		case k.ctrl:
+			sb.WriteString(`\c` + c)
+		case k.alt:
+			sb.WriteString(`\e` + c)
+		default:
+			sb.WriteString(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// nuKeys translates a key binding into the modifier and keycode fields of a nushell keybinding
+func nuKeys(keys []key) (string, error) {
+	if len(keys) != 1 {
+		return "", fmt.Errorf("nushell can only bind single keys")
+	}
+	k := keys[0]
+
+	modifier := "none"
+	switch {
+	case k.ctrl:
+		modifier = "control"
+	case k.alt:
+		modifier = "alt"
+	}
+
+	keycode := "space"
+	if k.name != "space" {
+		keycode = "char_" + k.name
+	}
+
+	return fmt.Sprintf("modifier: %s, keycode: %s", modifier, keycode), nil
+}
+
+// pwshKeys translates a key binding into a PSReadLine chord
+func pwshKeys(keys []key) (string, error) {
+	chords := []string{}
+	for _, k := range keys {
+		name := k.name
+		if name == "space" {
+			name = "SpaceBar"
+		}
+		switch {
+		case k.ctrl:
+			name = "Ctrl+" + name
+		case k.alt:
+			name = "Alt+" + name
+		}
+		chords = append(chords, name)
+	}
+	return strings.Join(chords, ","), nil
+}
+
+// elvishKeys translates a key binding for elvish's binding maps
+func elvishKeys(keys []key) (string, error) {
+	if len(keys) != 1 {
+		return "", fmt.Errorf("elvish can only bind single keys")
+	}
+	k := keys[0]
+
+	switch {
+	case k.ctrl && k.name == "space":
+		// The terminal sends Ctrl+Space as NUL, which elvish reads as Ctrl-`
+		return "Ctrl-'`'", nil
+	case k.ctrl:
+		return "Ctrl-" + strings.ToUpper(k.name), nil
+	case k.alt:
+		return "Alt-'" + k.char() + "'", nil
+	default:
+		return "'" + k.char() + "'", nil
+	}
+}
+
+// xonshKeys translates a key binding into the arguments of prompt_toolkit's bindings.add
+func xonshKeys(keys []key) (string, error) {
+	args := []string{}
+	for _, k := range keys {
+		name := k.name
+		switch {
+		case k.ctrl:
+			name = "c-" + name
+		case k.alt:
+			args = append(args, `"escape"`)
+		}
+		args = append(args, `"`+name+`"`)
+	}
+	return strings.Join(args, ", "), nil
+}
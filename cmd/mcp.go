@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito/pkg/xlog"
+	"github.com/mudler/wiz/chat"
+	"github.com/mudler/wiz/commands"
+	wizmcp "github.com/mudler/wiz/mcp"
+	"github.com/mudler/wiz/types"
+)
+
+// RunMCP implements the 'wiz mcp' commands
+func RunMCP(ctx context.Context, cfg types.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: wiz mcp list|serve")
+	}
+
+	switch args[0] {
+	case "list":
+		return listMCPServers(ctx, cfg)
+	case "serve":
+		return serveMCP(ctx, cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown mcp command %q, expected list or serve", args[0])
+	}
+}
+
+// listMCPServers connects to the configured MCP servers and prints their status and tools
+func listMCPServers(ctx context.Context, cfg types.Config) error {
+	transports, err := wizmcp.StartTransports(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	session, err := chat.NewSession(ctx, cfg, chat.Callbacks{}, transports...)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	fmt.Println(commands.FormatServers(session.Servers(), nil))
+	return nil
+}
+
+// serveMCP implements 'wiz mcp serve': it exposes the shell tool, and
+// optionally the wizard itself, to other MCP clients
+func serveMCP(ctx context.Context, cfg types.Config, args []string) error {
+	fs := flag.NewFlagSet("mcp serve", flag.ContinueOnError)
+	addr := fs.String("http", "", "Serve over streamable HTTP on this address (e.g. 127.0.0.1:8080) instead of stdio")
+	token := fs.String("token", os.Getenv("WIZ_MCP_TOKEN"), "Bearer token HTTP clients must send, required with --http (default $WIZ_MCP_TOKEN)")
+	allowRemote := fs.Bool("allow-remote", false, "Allow --http to listen on addresses other hosts can reach")
+	agent := fs.Bool("agent", false, "Also expose the ask_wiz tool, running the configured agent")
+	allowTools := fs.String("allow-tools", "", "Comma separated tools, or patterns like \"read_*\", ask_wiz may run; it can't run any other")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: wiz mcp serve [flags]\n\nExposes the bash tool to other MCP clients, over stdio unless --http is given.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	// Over stdio, stdout belongs to the protocol
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: xlog.LogLevel(cfg.LogLevel).ToSlogLevel()})
+	xlog.SetLogger(slog.New(types.RedactHandler(handler, cfg.Redact)))
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := wizmcp.NewShellServer(cfg.Redact)
+	if *agent {
+		addAgentTool(ctx, cfg, server, splitList(*allowTools))
+	}
+
+	if *addr == "" {
+		return wizmcp.ServeStdio(ctx, server)
+	}
+	return wizmcp.ServeHTTP(ctx, server, wizmcp.HTTPOptions{Addr: *addr, Token: *token, AllowRemote: *allowRemote})
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// askInput is the input of the ask_wiz tool
+type askInput struct {
+	Question string `json:"question" jsonschema:"the question or task for the wizard"`
+}
+
+// addAgentTool adds the ask_wiz tool, answering with a fresh session of the
+// configured agent. As nobody is there to approve its tool calls, it can only
+// run the tools matching allowed.
+func addAgentTool(ctx context.Context, cfg types.Config, server *mcp.Server, allowed []string) {
+	callbacks := chat.Callbacks{
+		OnToolCall: func(req chat.ToolCallRequest) chat.ToolCallResponse {
+			if chat.MatchesTool(req.Name, allowed) {
+				return chat.ToolCallResponse{Approved: true}
+			}
+			xlog.Warn("Denied tool call from ask_wiz, allow it with --allow-tools", "tool", req.Name)
+			return chat.ToolCallResponse{Approved: false}
+		},
+	}
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "ask_wiz",
+		Description: "Ask the wiz terminal assistant a question or give it a task. It can run shell commands and use its own MCP servers to answer.",
+	}, func(reqCtx context.Context, req *mcp.CallToolRequest, input askInput) (*mcp.CallToolResult, any, error) {
+		transports, err := wizmcp.StartTransports(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		session, err := chat.NewSession(ctx, cfg, callbacks, transports...)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer session.Close()
+
+		response, err := session.SendMessageContext(reqCtx, input.Question)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: cfg.Redact(response)}},
+		}, nil, nil
+	})
+}
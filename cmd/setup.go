@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mudler/wiz/config"
+	"github.com/mudler/wiz/types"
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+// provider is an OpenAI compatible API wiz can be set up with
+type provider struct {
+	Name    string
+	BaseURL string // Empty to ask for it
+	KeyEnv  string // Environment variable usually holding the API key
+	Model   string // Suggested model
+	Local   bool   // Runs locally, usually without an API key
+}
+
+var providers = []provider{
+	{Name: "OpenAI", BaseURL: "https://api.openai.com/v1", KeyEnv: "OPENAI_API_KEY", Model: "gpt-4o-mini"},
+	{Name: "OpenRouter", BaseURL: "https://openrouter.ai/api/v1", KeyEnv: "OPENROUTER_API_KEY", Model: "openai/gpt-4o-mini"},
+	{Name: "LocalAI", BaseURL: "http://localhost:8080/v1", Local: true},
+	{Name: "Ollama", BaseURL: "http://localhost:11434/v1", Local: true},
+	{Name: "Other OpenAI compatible API"},
+}
+
+// shellRC describes how to add the shell integration to a shell startup file
+type shellRC struct {
+	file string // Relative to the home directory
+	line string
+}
+
+// shellRCs are the shells whose integration setup can install
+var shellRCs = map[string]shellRC{
+	"zsh":    {".zshrc", `eval "$(wiz --init zsh)"`},
+	"bash":   {".bashrc", `eval "$(wiz --init bash)"`},
+	"fish":   {".config/fish/config.fish", "wiz --init fish | source"},
+	"elvish": {".config/elvish/rc.elv", "eval (wiz --init elvish | slurp)"},
+	"xonsh":  {".xonshrc", "execx($(wiz --init xonsh))"},
+}
+
+// probeTimeout bounds the requests made to check the endpoint
+const probeTimeout = 30 * time.Second
+
+// prompter asks questions on the terminal
+type prompter struct {
+	tty *os.File
+	in  *bufio.Reader
+}
+
+// ask asks a question, returning def when the answer is empty
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.tty, "%s %s[%s]%s ", question, colorGray, def, colorReset)
+	} else {
+		fmt.Fprintf(p.tty, "%s ", question)
+	}
+	answer, _ := p.in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
+
+// confirm asks a yes or no question
+func (p *prompter) confirm(question string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	answer := strings.ToLower(p.ask(question+" "+choices, ""))
+	if answer == "" {
+		return def
+	}
+	return strings.HasPrefix(answer, "y")
+}
+
+// secret asks for a value without echoing it
+func (p *prompter) secret(question string) string {
+	fmt.Fprintf(p.tty, "%s ", question)
+	value, err := term.ReadPassword(p.tty.Fd())
+	fmt.Fprintln(p.tty)
+	if err != nil {
+		// Not a real terminal, read the value as is
+		answer, _ := p.in.ReadString('\n')
+		return strings.TrimSpace(answer)
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// choose asks to pick one of options by number or by value, def being the
+// index of the default option
+func (p *prompter) choose(question string, options []string, def int) string {
+	for i, option := range options {
+		fmt.Fprintf(p.tty, "  %2d. %s\n", i+1, option)
+	}
+	for {
+		answer := p.ask(question, strconv.Itoa(def+1))
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n >= 1 && n <= len(options) {
+				return options[n-1]
+			}
+			fmt.Fprintf(p.tty, "%sPick a number between 1 and %d%s\n", colorYellow, len(options), colorReset)
+			continue
+		}
+		return answer
+	}
+}
+
+// RunSetup implements 'wiz setup': it asks for the provider, API key and
+// model, checks they work, and writes the user config. firstRun tells it was
+// started because no config exists.
+func RunSetup(firstRun bool) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("setup needs a terminal: %w", err)
+	}
+	defer tty.Close()
+	p := &prompter{tty: tty, in: bufio.NewReader(tty)}
+
+	fmt.Fprintf(tty, "%s%s✨ [◠ ◠] wiz setup%s\n", colorBold, colorPurple, colorReset)
+	if firstRun {
+		fmt.Fprintf(tty, "%sNo configuration found, let's create one. Press Ctrl+C to quit.%s\n", colorGray, colorReset)
+	}
+	fmt.Fprintln(tty)
+
+	// Provider
+	names := make([]string, len(providers))
+	for i, prov := range providers {
+		names[i] = prov.Name
+	}
+	choice := p.choose("Provider:", names, 0)
+	prov := provider{Name: choice}
+	for _, candidate := range providers {
+		if candidate.Name == choice {
+			prov = candidate
+		}
+	}
+
+	cfg := types.Config{BaseURL: prov.BaseURL}
+	if prov.BaseURL == "" || prov.Local {
+		cfg.BaseURL = p.ask("API URL:", prov.BaseURL)
+	}
+
+	// API key, preferably kept out of the config file
+	switch {
+	case prov.KeyEnv != "" && os.Getenv(prov.KeyEnv) != "" && p.confirm(fmt.Sprintf("Use the API key in $%s?", prov.KeyEnv), true):
+		cfg.APIKey = "${env:" + prov.KeyEnv + "}"
+	case prov.Local:
+		cfg.APIKey = p.ask("API key, if the server needs one:", "")
+	default:
+		fmt.Fprintf(tty, "%sType the key, or a reference to it like ${env:NAME} or ${cmd:pass show openai}.%s\n", colorGray, colorReset)
+		cfg.APIKey = p.secret("API key:")
+	}
+	apiKey, err := config.ResolveSecrets(cfg.APIKey)
+	if err != nil {
+		fmt.Fprintf(tty, "%s⚠ Could not read the API key: %v%s\n", colorYellow, err, colorReset)
+	}
+
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.BaseURL = cfg.BaseURL
+	client := openai.NewClientWithConfig(clientConfig)
+
+	// Model, among the ones the endpoint serves
+	fmt.Fprintf(tty, "%sListing the models of %s...%s\n", colorGray, cfg.BaseURL, colorReset)
+	models, err := listModels(client)
+	if err != nil {
+		fmt.Fprintf(tty, "%s⚠ Could not list the models: %v%s\n", colorYellow, err, colorReset)
+		cfg.Model = p.ask("Model:", prov.Model)
+	} else {
+		def := 0
+		for i, model := range models {
+			if model == prov.Model {
+				def = i
+			}
+		}
+		cfg.Model = p.choose("Model (number or name):", models, def)
+	}
+
+	// Check that a completion works before saving
+	fmt.Fprintf(tty, "%sAsking %s for a test answer...%s\n", colorGray, cfg.Model, colorReset)
+	if err := testCompletion(client, cfg.Model); err != nil {
+		fmt.Fprintf(tty, "%s⚠ The test failed: %v%s\n", colorRed, err, colorReset)
+		if !p.confirm("Save the configuration anyway?", false) {
+			return errors.New("setup cancelled")
+		}
+	} else {
+		fmt.Fprintf(tty, "%s✓ %s answered%s\n", colorGreen, cfg.Model, colorReset)
+	}
+
+	if err := writeConfig(p, cfg); err != nil {
+		return err
+	}
+
+	setupShellIntegration(p)
+	fmt.Fprintln(tty)
+	return nil
+}
+
+// listModels returns the models served by the endpoint, sorted
+func listModels(client *openai.Client) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	list, err := client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Models) == 0 {
+		return nil, errors.New("the endpoint serves no models")
+	}
+
+	models := make([]string, len(list.Models))
+	for i, model := range list.Models {
+		models[i] = model.ID
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// testCompletion asks the model for a short answer
+func testCompletion(client *openai.Client, model string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	_, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "Reply with OK."},
+		},
+	})
+	return err
+}
+
+// writeConfig writes the user config, after confirming it replaces an existing one
+func writeConfig(p *prompter, cfg types.Config) error {
+	path := config.UserConfigPath()
+	if path == "" {
+		return errors.New("can't find the home directory to write the config")
+	}
+	if _, err := os.Stat(path); err == nil && !p.confirm(fmt.Sprintf("Replace %s?", path), false) {
+		return errors.New("setup cancelled, the existing config was kept")
+	}
+
+	settings := struct {
+		Model   string `yaml:"model"`
+		APIKey  string `yaml:"api_key,omitempty"`
+		BaseURL string `yaml:"base_url"`
+	}{cfg.Model, cfg.APIKey, cfg.BaseURL}
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	data = append([]byte("# Written by 'wiz setup', see https://github.com/mudler/wiz#configuration for more settings\n"), data...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// The file may hold the API key
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.tty, "%s✓ Wrote %s%s\n", colorGreen, path, colorReset)
+	return nil
+}
+
+// setupShellIntegration offers to add the Ctrl+Space widget to the startup
+// file of the user's shell
+func setupShellIntegration(p *prompter) {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	rc, ok := shellRCs[shell]
+	home, err := os.UserHomeDir()
+	if !ok || err != nil {
+		fmt.Fprintf(p.tty, "%sTo summon wiz with Ctrl+Space, add its shell integration: see 'wiz --init'.%s\n", colorGray, colorReset)
+		return
+	}
+
+	path := filepath.Join(home, rc.file)
+	if data, err := os.ReadFile(path); err == nil && strings.Contains(string(data), "wiz --init") {
+		return
+	}
+	if !p.confirm(fmt.Sprintf("Summon wiz with Ctrl+Space in %s? This adds a line to %s.", shell, path), true) {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(p.tty, "%s⚠ %v%s\n", colorYellow, err, colorReset)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(p.tty, "%s⚠ %v%s\n", colorYellow, err, colorReset)
+		return
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\n# wiz shell integration\n%s\n", rc.line); err != nil {
+		fmt.Fprintf(p.tty, "%s⚠ %v%s\n", colorYellow, err, colorReset)
+		return
+	}
+	fmt.Fprintf(p.tty, "%s✓ Added the shell integration to %s, open a new shell to use it%s\n", colorGreen, path, colorReset)
+}
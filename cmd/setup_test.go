@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mudler/wiz/types"
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeAPI serves the OpenAI endpoints used by the setup, with the given models
+func fakeAPI(t *testing.T, models []string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+			return
+		}
+		list := openai.ModelsList{}
+		for _, model := range models {
+			list.Models = append(list.Models, openai.Model{ID: model, Object: "model"})
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !slices.Contains(models, req.Model) {
+			http.Error(w, `{"error":{"message":"unknown model"}}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "OK"}},
+			},
+		})
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// fakeClient returns a client for the API at url
+func fakeClient(url, key string) *openai.Client {
+	clientConfig := openai.DefaultConfig(key)
+	clientConfig.BaseURL = url + "/v1"
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+func TestListModels(t *testing.T) {
+	ts := fakeAPI(t, []string{"zeta", "alpha", "mid"})
+
+	models, err := listModels(fakeClient(ts.URL, "test-key"))
+	if err != nil {
+		t.Fatalf("listModels: %v", err)
+	}
+	if want := []string{"alpha", "mid", "zeta"}; !slices.Equal(models, want) {
+		t.Fatalf("models = %v, want %v", models, want)
+	}
+
+	if _, err := listModels(fakeClient(ts.URL, "wrong-key")); err == nil {
+		t.Fatal("expected an error with a wrong key")
+	}
+}
+
+func TestListModelsEmpty(t *testing.T) {
+	ts := fakeAPI(t, nil)
+	if _, err := listModels(fakeClient(ts.URL, "test-key")); err == nil {
+		t.Fatal("expected an error when the endpoint serves no models")
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	ts := fakeAPI(t, []string{"small"})
+	client := fakeClient(ts.URL, "test-key")
+
+	if err := testCompletion(client, "small"); err != nil {
+		t.Fatalf("testCompletion: %v", err)
+	}
+	if err := testCompletion(client, "missing"); err == nil {
+		t.Fatal("expected an error for a model the endpoint doesn't serve")
+	}
+}
+
+// testPrompter returns a prompter answering with answers, discarding its output
+func testPrompter(t *testing.T, answers string) *prompter {
+	t.Helper()
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+	return &prompter{tty: out, in: bufio.NewReader(strings.NewReader(answers))}
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "wiz", "config.yaml")
+
+	cfg := types.Config{Model: "gpt-4o-mini", APIKey: "${env:OPENAI_API_KEY}", BaseURL: "https://api.openai.com/v1"}
+	if err := writeConfig(testPrompter(t, ""), cfg); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("config mode = %o, want 600 as it may hold the API key", mode)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]string
+	if err := yaml.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid YAML written: %v\n%s", err, data)
+	}
+	want := map[string]string{"model": cfg.Model, "api_key": cfg.APIKey, "base_url": cfg.BaseURL}
+	for key, value := range want {
+		if written[key] != value {
+			t.Errorf("%s = %q, want %q", key, written[key], value)
+		}
+	}
+	if len(written) != len(want) {
+		t.Errorf("unexpected settings written: %v", written)
+	}
+
+	// Local servers may need no key, it is then left out
+	if err := writeConfig(testPrompter(t, "y\n"), types.Config{Model: "llama", BaseURL: "http://localhost:8080/v1"}); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "api_key") {
+		t.Errorf("empty api_key written:\n%s", data)
+	}
+}
+
+func TestWriteConfigKeepsExisting(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "wiz", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("model: mine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeConfig(testPrompter(t, "n\n"), types.Config{Model: "other"}); err == nil {
+		t.Fatal("expected an error when not replacing the existing config")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "model: mine\n" {
+		t.Fatalf("existing config changed:\n%s", data)
+	}
+}
@@ -4,15 +4,27 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mudler/wiz/types"
 )
 
+// forwardedEnv are the environment variables passed to the wiz running in tmux.
+// Commands started by tmux inherit the server environment, not ours.
+var forwardedEnv = []string{"WIZ_LAST_COMMAND", "WIZ_LAST_STATUS", "WIZ_LAST_STDERR"}
+
 // inTmux returns true if running inside tmux
 func IsInTmux() bool {
 	return os.Getenv("TMUX") != "" && os.Getenv("TMUX_PANE") != ""
 }
 
-// runTmuxSplit runs wiz in a tmux split pane (like fzf-tmux -d)
-func RunTmuxSplit(height string) error {
+// RunTmux runs wiz in a tmux popup or split pane (like fzf-tmux) and returns
+// the command chosen there, so it can be given back to the calling shell widget.
+// args are passed to the wiz running in tmux.
+func RunTmux(opts types.TmuxOptions, height string, args ...string) (string, error) {
 	// Get current working directory
 	dir, err := os.Getwd()
 	if err != nil {
@@ -25,29 +37,174 @@ func RunTmuxSplit(height string) error {
 		executable = "wiz"
 	}
 
-	// Build the command to run inside the split pane
+	// The wiz in tmux writes its result to a file
+	result, err := os.CreateTemp("", "wiz-result-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create result file: %w", err)
+	}
+	result.Close()
+	defer os.Remove(result.Name())
+
+	// Build the command to run inside tmux. It fills the popup or pane.
 	// Use --no-tmux to prevent infinite recursion
-	wizCmd := fmt.Sprintf("%s --height %s --no-tmux", executable, height)
+	wizArgs := append([]string{executable, "--height", "100%", "--no-tmux", "--output", result.Name()}, args...)
+	wizCmd := envAssignments() + shellJoin(wizArgs)
+
+	if useTmuxPopup(opts) {
+		// display-popup -E returns once the popup is closed, however wiz exits
+		cmd := exec.Command("tmux", popupArgs(opts, height, dir, wizCmd)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", err
+		}
+	} else {
+		cmd := exec.Command("tmux", splitArgs(height, dir, wizCmd)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		pane, err := cmd.Output()
+		if err != nil {
+			return "", err
+		}
+		waitPane(strings.TrimSpace(string(pane)))
+	}
+
+	output, err := os.ReadFile(result.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
 
+// popupArgs returns the tmux arguments to run wizCmd in a popup
+func popupArgs(opts types.TmuxOptions, height, dir, wizCmd string) []string {
+	if opts.Height != "" {
+		height = opts.Height
+	}
+
+	// display-popup arguments
+	// -E: close the popup when the command exits
+	// -w/-h: size of the popup
+	// -x/-y: position of the popup
+	// -d: working directory
+	args := []string{"display-popup", "-E", "-d", dir}
+	if opts.Width != "" {
+		args = append(args, "-w", opts.Width)
+	}
+	if height != "" {
+		args = append(args, "-h", height)
+	}
+	if opts.X != "" {
+		args = append(args, "-x", opts.X)
+	}
+	if opts.Y != "" {
+		args = append(args, "-y", opts.Y)
+	}
+	// The popup runs its command with the user's default shell, make sure it's a POSIX one
+	return append(args, "sh -c "+shellQuote(wizCmd))
+}
+
+// splitArgs returns the tmux arguments to run wizCmd in a split pane below
+// the current one, printing the ID of the new pane
+func splitArgs(height, dir, wizCmd string) []string {
 	// tmux split-window arguments
-	// -d: don't switch focus to new pane initially (we'll switch after)
 	// -v: vertical split (new pane below)
 	// -l: size of the new pane
 	// -c: working directory
-	//
-	// After split, we swap panes so the new pane is below and select it
-	tmuxArgs := []string{
+	// -P -F: print the ID of the new pane
+	return []string{
 		"split-window",
 		"-v",         // vertical split (creates pane below)
 		"-l", height, // height of the new pane
 		"-c", dir, // working directory
+		"-P", "-F", "#{pane_id}",
 		"sh", "-c", wizCmd,
 	}
+}
+
+// panePollInterval is how often waitPane checks the pane
+const panePollInterval = 100 * time.Millisecond
+
+// waitPane waits until the tmux pane is closed, or its command exited when
+// the pane remains on exit. This works however the pane ends, even when
+// killed or when wiz crashed.
+func waitPane(pane string) {
+	for paneAlive(pane) {
+		time.Sleep(panePollInterval)
+	}
+}
+
+// paneAlive returns true if the tmux pane exists and its command is running
+func paneAlive(pane string) bool {
+	// display-message falls back to the current pane for missing targets,
+	// list all the panes instead
+	out, err := exec.Command("tmux", "list-panes", "-a", "-F", "#{pane_id} #{pane_dead}").Output()
+	if err != nil {
+		// The tmux server is gone
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if id, dead, _ := strings.Cut(line, " "); id == pane {
+			return dead != "1"
+		}
+	}
+	return false
+}
+
+// useTmuxPopup returns true if wiz should run in a popup rather than a split pane
+func useTmuxPopup(opts types.TmuxOptions) bool {
+	switch opts.Mode {
+	case "split":
+		return false
+	case "popup":
+		return true
+	default:
+		// Popups are available since tmux 3.2
+		return tmuxVersionAtLeast(3, 2)
+	}
+}
+
+var tmuxVersionRegexp = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// tmuxVersionAtLeast returns true if the installed tmux is at least major.minor
+func tmuxVersionAtLeast(major, minor int) bool {
+	out, err := exec.Command("tmux", "-V").Output()
+	if err != nil {
+		return false
+	}
+
+	// e.g. "tmux 3.3a" or "tmux next-3.4"
+	m := tmuxVersionRegexp.FindStringSubmatch(string(out))
+	if m == nil {
+		return false
+	}
+	gotMajor, _ := strconv.Atoi(m[1])
+	gotMinor, _ := strconv.Atoi(m[2])
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
 
-	cmd := exec.Command("tmux", tmuxArgs...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// envAssignments returns the shell assignments forwarding our environment to the wiz in tmux
+func envAssignments() string {
+	var sb strings.Builder
+	for _, name := range forwardedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			sb.WriteString(name + "=" + shellQuote(value) + " ")
+		}
+	}
+	return sb.String()
+}
+
+// shellJoin quotes and joins arguments into a shell command line
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
 
-	return cmd.Run()
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
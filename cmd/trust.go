@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mudler/wiz/config"
+)
+
+// AskTrust asks whether to trust the config of the current directory, when it
+// was never decided or the config changed since. Without a terminal to ask
+// on, the config stays ignored.
+func AskTrust() error {
+	project := config.Project()
+	if project == nil || !project.Undecided() {
+		return nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil
+	}
+	defer tty.Close()
+
+	files := strings.Join(project.Files(), " and ")
+	if project.State == config.TrustChanged {
+		fmt.Fprintf(tty, "%s⚠ %s changed since you last decided about it.%s\n", colorYellow, files, colorReset)
+	} else {
+		fmt.Fprintf(tty, "%s⚠ This directory has a wiz config, %s.%s\n", colorYellow, files, colorReset)
+	}
+	fmt.Fprintln(tty, project.Summary())
+	fmt.Fprintf(tty, "%sOnly trust configs you wrote or reviewed: they can start commands as MCP servers and change what commands ask. They can't change api_key or base_url.%s\n", colorGray, colorReset)
+	fmt.Fprintf(tty, "Trust it? [y/N] ")
+
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return nil
+	}
+	trusted := strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+	if err := project.Decide(trusted); err != nil {
+		return err
+	}
+	if !trusted {
+		fmt.Fprintf(tty, "%sIgnoring it. Run 'wiz config trust' if you change your mind.%s\n", colorGray, colorReset)
+	}
+	return nil
+}
@@ -14,22 +14,23 @@ import (
 	"github.com/mudler/wiz/types"
 )
 
-// runTUI runs the Bubble Tea TUI
-func RunTUI(ctx context.Context, cfg types.Config, height int, transports ...mcp.Transport) error {
+// runTUI runs the Bubble Tea TUI and returns the command line to give back to the shell, if any.
+// If query is not empty, it is sent as soon as the session is ready.
+func RunTUI(ctx context.Context, cfg types.Config, height int, query string, transports ...mcp.Transport) (string, error) {
 
-	model := tui.NewModel(ctx, cfg, height, transports...)
+	model := tui.NewModel(ctx, cfg, height, transports...).WithQuery(query)
 
 	// Open /dev/tty directly for TUI - this is crucial when stdout is being captured
 	// (e.g., when run from a shell widget like `output=$(wiz --height 40%)`)
 	ttyIn, err := os.OpenFile("/dev/tty", os.O_RDONLY, 0)
 	if err != nil {
-		return fmt.Errorf("failed to open /dev/tty for reading: %w", err)
+		return "", fmt.Errorf("failed to open /dev/tty for reading: %w", err)
 	}
 	defer ttyIn.Close()
 
 	ttyOut, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
 	if err != nil {
-		return fmt.Errorf("failed to open /dev/tty for writing: %w", err)
+		return "", fmt.Errorf("failed to open /dev/tty for writing: %w", err)
 	}
 	defer ttyOut.Close()
 
@@ -74,17 +75,17 @@ func RunTUI(ctx context.Context, cfg types.Config, height int, transports ...mcp
 	fmt.Fprint(ttyOut, "\x1b[J") // Clear from cursor to end of screen
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Output any command to shell if needed (this goes to real stdout for shell capture)
+	// Return any command for the shell. The shell widget replaces its command line with it.
 	if m, ok := finalModel.(tui.Model); ok {
-		if output := m.Output(); output != "" {
-			fmt.Print(output)
+		if output, mode := m.Output(); output != "" {
+			return MergeCommandLine(cfg.CommandLine, cfg.CommandLineCursor, output, mode), nil
 		}
 	}
 
-	return nil
+	return "", nil
 }
 
 // getTerminalHeight returns the terminal height
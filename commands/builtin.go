@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mudler/wiz/chat"
+)
+
+// Default returns a registry with all the built-in commands
+func Default() *Registry {
+	r := NewRegistry()
+
+	r.Register(Command{
+		Name:        "help",
+		Description: "Show this help message",
+		Run: func(env Env, args string) (Result, error) {
+			return Result{Output: r.Help()}, nil
+		},
+	})
+	r.Register(Command{
+		Name:        "exit",
+		Description: "Exit the wizard",
+		Run: func(env Env, args string) (Result, error) {
+			return Result{Exit: true}, nil
+		},
+	})
+	r.Register(Command{
+		Name:        "clear",
+		Description: "Clear the conversation",
+		Run: func(env Env, args string) (Result, error) {
+			env.Session.ClearHistory()
+			return Result{Output: "Conversation cleared", Clear: true}, nil
+		},
+	})
+	r.Register(Command{
+		Name:        "model",
+		Usage:       "[name]",
+		Description: "Show or switch the model",
+		Run:         runModel,
+	})
+	r.Register(Command{
+		Name:        "save",
+		Usage:       "[file]",
+		Description: "Save the conversation as Markdown",
+		Run:         runSave,
+	})
+	r.Register(Command{
+		Name:        "export",
+		Usage:       "[markdown|json|shell] [file]",
+		Description: "Export the conversation, or the commands run as a shell script",
+		Run:         runExport,
+	})
+	r.Register(Command{
+		Name:        "tools",
+		Description: "List the MCP servers, their status and their tools",
+		Run:         runTools,
+	})
+	r.Register(Command{
+		Name:        "resources",
+		Usage:       "[uri]",
+		Description: "List the MCP resources, or show one of them",
+		Run:         runResources,
+	})
+	r.Register(Command{
+		Name:        "allow",
+		Usage:       "[tool]",
+		Description: "Show the allow list or add a tool to it",
+		Run:         runAllow,
+	})
+	r.Register(Command{
+		Name:        "history",
+		Description: "Show the conversation so far",
+		Run:         runHistory,
+	})
+	r.Register(Command{
+		Name:        "retry",
+		Usage:       "[model]",
+		Description: "Answer the last question again, optionally with another model",
+		Run:         runRetry,
+	})
+	r.Register(Command{
+		Name:        "fork",
+		Usage:       "[exchange]",
+		Description: "List the exchanges, or start a new branch after one of them",
+		Run:         runFork,
+	})
+	r.Register(Command{
+		Name:        "branches",
+		Description: "List the branches of the conversation",
+		Run:         runBranches,
+	})
+	r.Register(Command{
+		Name:        "branch",
+		Usage:       "<id>",
+		Description: "Switch to another branch of the conversation",
+		Run:         runBranch,
+	})
+	r.Register(Command{
+		Name:        "edit",
+		Usage:       "[question]",
+		Description: "Replace the last question and its answer",
+		Run:         runEdit,
+	})
+
+	return r
+}
+
+func runModel(env Env, args string) (Result, error) {
+	if args == "" {
+		return Result{Output: "Current model: " + env.Session.Model()}, nil
+	}
+	env.Session.SetModel(args)
+	return Result{Output: "Switched model to " + args}, nil
+}
+
+func runSave(env Env, args string) (Result, error) {
+	return export(env, "markdown", args)
+}
+
+func runExport(env Env, args string) (Result, error) {
+	format, path, _ := strings.Cut(args, " ")
+	if format == "" {
+		format = "markdown"
+	}
+	return export(env, format, strings.TrimSpace(path))
+}
+
+// export writes the conversation to path, or to a timestamped file if empty
+func export(env Env, format, path string) (Result, error) {
+	content, err := env.Session.Export().Format(format)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if path == "" {
+		path = fmt.Sprintf("wiz-%s%s", time.Now().Format("20060102-150405"), exportExtensions[format])
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return Result{}, fmt.Errorf("failed to export conversation: %w", err)
+	}
+	return Result{Output: "Conversation exported to " + path}, nil
+}
+
+// exportExtensions are the file extensions of the export formats
+var exportExtensions = map[string]string{
+	"markdown": ".md",
+	"md":       ".md",
+	"json":     ".json",
+	"shell":    ".sh",
+	"sh":       ".sh",
+}
+
+func runTools(env Env, args string) (Result, error) {
+	servers := env.Session.Servers()
+	if len(servers) == 0 {
+		return Result{Output: "No MCP servers configured"}, nil
+	}
+	return Result{Output: FormatServers(servers, env.Session.AllowedTools())}, nil
+}
+
+// maxStderrLines bounds the lines of server error output shown
+const maxStderrLines = 5
+
+// FormatServers describes the MCP servers, their state and their tools.
+// Tools in allowed are marked.
+func FormatServers(servers []chat.ServerStatus, allowed []string) string {
+	isAllowed := map[string]bool{}
+	for _, name := range allowed {
+		isAllowed[name] = true
+	}
+
+	var sb strings.Builder
+	for i, server := range servers {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		details := []string{server.State}
+		if server.PID != 0 && server.State == chat.ServerRunning {
+			details = append(details, fmt.Sprintf("pid %d", server.PID))
+		}
+		if server.Restarts > 0 {
+			details = append(details, fmt.Sprintf("%d restarts", server.Restarts))
+		}
+		sb.WriteString(fmt.Sprintf("%s [%s]\n", server.Name, strings.Join(details, ", ")))
+
+		if server.Error != "" && server.State != chat.ServerRunning {
+			sb.WriteString("  error: " + firstLine(server.Error) + "\n")
+		}
+		if stderr := strings.TrimSpace(server.Stderr); stderr != "" && server.State != chat.ServerRunning {
+			lines := strings.Split(stderr, "\n")
+			if len(lines) > maxStderrLines {
+				lines = lines[len(lines)-maxStderrLines:]
+			}
+			sb.WriteString("  stderr:\n")
+			for _, line := range lines {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
+
+		for _, tool := range server.Tools {
+			mark := " "
+			if isAllowed[tool.Name] {
+				mark = "✓"
+			}
+			sb.WriteString(fmt.Sprintf("  %s %s", mark, tool.Name))
+			if tool.Description != "" {
+				sb.WriteString(" - " + firstLine(tool.Description))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func runResources(env Env, args string) (Result, error) {
+	if args != "" {
+		content, err := env.Session.ReadResource(args)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{Output: content}, nil
+	}
+
+	resources, err := env.Session.ListResources()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to list resources: %w", err)
+	}
+	if len(resources) == 0 {
+		return Result{Output: "No MCP resources available"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Resources (mention them with @uri to attach them to a message):\n")
+	for _, resource := range resources {
+		sb.WriteString(fmt.Sprintf("  %s (%s)", resource.URI, resource.Server))
+		if resource.Description != "" {
+			sb.WriteString(" - " + firstLine(resource.Description))
+		} else if resource.Name != "" && resource.Name != resource.URI {
+			sb.WriteString(" - " + resource.Name)
+		}
+		sb.WriteString("\n")
+	}
+	return Result{Output: strings.TrimRight(sb.String(), "\n")}, nil
+}
+
+func runAllow(env Env, args string) (Result, error) {
+	tools, err := env.Session.ListTools()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to list tools: %w", err)
+	}
+
+	if args == "" {
+		allowed := env.Session.AllowedTools()
+		if len(allowed) == 0 {
+			return Result{Output: "No tools in the allow list"}, nil
+		}
+
+		servers := map[string]string{}
+		for _, tool := range tools {
+			servers[tool.Name] = tool.Server
+		}
+		for i, name := range allowed {
+			if server := servers[name]; server != "" {
+				allowed[i] = fmt.Sprintf("%s (%s)", name, server)
+			}
+		}
+		return Result{Output: "Allowed tools: " + strings.Join(allowed, ", ")}, nil
+	}
+
+	for _, tool := range tools {
+		if tool.Name == args {
+			env.Session.AllowTool(args)
+			return Result{Output: fmt.Sprintf("Tool '%s' from %s added to allow list for this session", args, tool.Server)}, nil
+		}
+	}
+	return Result{}, fmt.Errorf("unknown tool %q, see %stools", args, Prefix)
+}
+
+func runHistory(env Env, args string) (Result, error) {
+	messages := env.Session.GetMessages()
+	if len(messages) == 0 {
+		return Result{Output: "The conversation is empty"}, nil
+	}
+
+	var sb strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case "user":
+			sb.WriteString("👤 You: ")
+		case "assistant":
+			sb.WriteString("🧙 Wiz: ")
+		default:
+			continue
+		}
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n")
+	}
+	return Result{Output: strings.TrimRight(sb.String(), "\n")}, nil
+}
+
+func runRetry(env Env, args string) (Result, error) {
+	last, err := env.Session.Rewind()
+	if err != nil {
+		return Result{}, errors.New("nothing to retry yet")
+	}
+
+	result := Result{Send: last, Rewind: true}
+	if args != "" {
+		env.Session.SetModel(args)
+		result.Output = "Switched model to " + args
+	}
+	return result, nil
+}
+
+func runEdit(env Env, args string) (Result, error) {
+	last, err := env.Session.Rewind()
+	if err != nil {
+		return Result{}, errors.New("nothing to edit yet")
+	}
+
+	if args == "" {
+		// Let the user edit the question before sending it again
+		return Result{Input: last, Rewind: true}, nil
+	}
+	return Result{Send: args, Rewind: true}, nil
+}
+
+func runFork(env Env, args string) (Result, error) {
+	if args == "" {
+		var sb strings.Builder
+		sb.WriteString("Exchanges in this branch:\n")
+		n := 0
+		for _, msg := range env.Session.GetMessages() {
+			if msg.Role == "user" {
+				n++
+				sb.WriteString(fmt.Sprintf("  %d. %s\n", n, firstLine(msg.Content)))
+			}
+		}
+		if n == 0 {
+			return Result{Output: "The conversation is empty"}, nil
+		}
+		sb.WriteString(fmt.Sprintf("Use %sfork <exchange> to continue after one of them, 0 to start over", Prefix))
+		return Result{Output: sb.String()}, nil
+	}
+
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid exchange %q, expected a number", args)
+	}
+	if err := env.Session.Fork(n); err != nil {
+		return Result{}, err
+	}
+	return Result{Output: "The next message starts a new branch", Reload: true}, nil
+}
+
+func runBranches(env Env, args string) (Result, error) {
+	branches := env.Session.Branches()
+	if len(branches) == 0 {
+		return Result{Output: "The conversation is empty"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Branches:\n")
+	for _, branch := range branches {
+		mark, exchanges := " ", fmt.Sprintf("%d exchanges", branch.Turns)
+		if branch.Current {
+			mark = "*"
+			if branch.At < branch.Turns {
+				exchanges = fmt.Sprintf("%d exchanges, going on from exchange %d", branch.Turns, branch.At)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("  %s %d (%s) %s\n", mark, branch.ID, exchanges, firstLine(branch.Question)))
+	}
+	return Result{Output: strings.TrimRight(sb.String(), "\n")}, nil
+}
+
+func runBranch(env Env, args string) (Result, error) {
+	id, err := strconv.Atoi(args)
+	if err != nil {
+		return Result{}, fmt.Errorf("usage: %sbranch <id>, see %sbranches", Prefix, Prefix)
+	}
+	if err := env.Session.SwitchBranch(id); err != nil {
+		return Result{}, err
+	}
+	return Result{Output: fmt.Sprintf("Switched to branch %d", id), Reload: true}, nil
+}
+
+// firstLine returns the first line of a possibly multi-line text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mudler/wiz/chat"
+	"github.com/mudler/wiz/types"
+)
+
+// Prefix is the character that marks an input line as a slash command
+const Prefix = "/"
+
+// Env holds what a command can act upon
+type Env struct {
+	Session *chat.Session
+	Config  types.Config
+}
+
+// Result tells the frontend what to do after a command ran
+type Result struct {
+	Output string // Text to show to the user
+	Send   string // Message to send to the assistant, if any
+	Input  string // Text to put in the input area for editing, if any
+	Clear  bool   // The conversation was cleared
+	Rewind bool   // The last exchange was removed from the conversation
+	Reload bool   // The conversation changed branch and should be shown again
+	Exit   bool   // The frontend should exit
+}
+
+// Handler runs a command with the raw arguments typed after its name
+type Handler func(env Env, args string) (Result, error)
+
+// Command is a slash command available in both the CLI and the TUI
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         Handler
+}
+
+// Registry holds the available slash commands
+type Registry struct {
+	commands map[string]Command
+	// pendingPrompts lists the MCP prompts not registered yet. Listing them
+	// connects to every server, so it waits until they are needed.
+	pendingPrompts func() ([]chat.PromptInfo, error)
+}
+
+// NewRegistry creates an empty command registry
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]Command)}
+}
+
+// Register adds a command to the registry, replacing any command with the same name
+func (r *Registry) Register(cmd Command) {
+	r.commands[cmd.Name] = cmd
+}
+
+// Commands returns all the registered commands, sorted by name
+func (r *Registry) Commands() []Command {
+	cmds := make([]Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Lookup finds a command by name. An unambiguous prefix of a name also matches.
+func (r *Registry) Lookup(name string) (Command, bool) {
+	if cmd, ok := r.commands[name]; ok {
+		return cmd, true
+	}
+
+	matches := r.matching(name)
+	if len(matches) == 1 {
+		return r.commands[matches[0]], true
+	}
+	return Command{}, false
+}
+
+// matching returns the sorted names of the commands starting with prefix
+func (r *Registry) matching(prefix string) []string {
+	names := []string{}
+	for name := range r.commands {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Complete returns the completions for a partially typed command line.
+// Only the command name is completed, arguments are left to the user.
+func (r *Registry) Complete(line string) []string {
+	if !HasPrefix(line) || strings.ContainsAny(line, " \t") {
+		return nil
+	}
+
+	completions := []string{}
+	for _, name := range r.matching(strings.TrimPrefix(line, Prefix)) {
+		completions = append(completions, Prefix+name)
+	}
+	return completions
+}
+
+// CommonPrefix returns the longest prefix shared by all the given
+// completions, to complete the line as far as they agree
+func CommonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// Help returns the help text listing all the commands
+func (r *Registry) Help() string {
+	var sb strings.Builder
+	sb.WriteString("Available commands:\n")
+
+	width := 0
+	for _, cmd := range r.Commands() {
+		if l := len(usage(cmd)); l > width {
+			width = l
+		}
+	}
+	for _, cmd := range r.Commands() {
+		sb.WriteString(fmt.Sprintf("  %-*s  %s\n", width, usage(cmd), cmd.Description))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// usage returns how a command is invoked, e.g. "/model [name]"
+func usage(cmd Command) string {
+	if cmd.Usage == "" {
+		return Prefix + cmd.Name
+	}
+	return Prefix + cmd.Name + " " + cmd.Usage
+}
+
+// Execute parses and runs a command line such as "/model gpt-4o"
+func (r *Registry) Execute(env Env, line string) (Result, error) {
+	if r.NeedsPrompts(line) {
+		r.LoadPrompts()
+	}
+
+	name, args := Parse(line)
+
+	cmd, ok := r.Lookup(name)
+	if !ok {
+		if matches := r.matching(name); len(matches) > 1 {
+			return Result{}, fmt.Errorf("ambiguous command %s%s, could be: %s%s", Prefix, name, Prefix, strings.Join(matches, ", "+Prefix))
+		}
+		return Result{}, fmt.Errorf("unknown command %s%s, type %shelp for a list of commands", Prefix, name, Prefix)
+	}
+
+	return cmd.Run(env, args)
+}
+
+// IsCommand returns true if the input line runs a command: its first word
+// is the name, or the start of the name of a single command. Other lines
+// starting with "/", like "/var/log/syslog is huge, why?", "/ foo" or a
+// prefix matching several commands, are questions. The MCP prompts are
+// listed first when the line could be one of them.
+func (r *Registry) IsCommand(line string) bool {
+	if !HasPrefix(line) {
+		return false
+	}
+	if r.NeedsPrompts(line) {
+		r.LoadPrompts()
+	}
+	name, _ := Parse(line)
+	if name == "" {
+		return false
+	}
+	_, ok := r.Lookup(name)
+	return ok
+}
+
+// HasPrefix returns true if the input line starts like a slash command
+func HasPrefix(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), Prefix)
+}
+
+// Parse splits a command line into the command name and its raw arguments
+func Parse(line string) (string, string) {
+	line = strings.TrimPrefix(strings.TrimSpace(line), Prefix)
+	name, args, _ := strings.Cut(line, " ")
+	return name, strings.TrimSpace(args)
+}
@@ -0,0 +1,51 @@
+package commands
+
+import "testing"
+
+func TestIsCommand(t *testing.T) {
+	r := Default()
+	tests := map[string]bool{
+		"/help":                          true,
+		"  /model gpt-4o":                true,
+		"/he":                            true,  // Prefix of /help only
+		"/h":                             false, // /help, /history
+		"/":                              false,
+		"/ foo":                          false,
+		"/var/log/syslog is huge, why?":  false,
+		"/nonexistent":                   false,
+		"what does /help do?":            false,
+		"list the files in /etc, please": false,
+	}
+	for line, want := range tests {
+		if got := r.IsCommand(line); got != want {
+			t.Errorf("IsCommand(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := Default()
+	if got := r.Complete("/hel"); len(got) != 1 || got[0] != "/help" {
+		t.Errorf("Complete(/hel) = %v, want [/help]", got)
+	}
+	if got := r.Complete("/help me"); got != nil {
+		t.Errorf("arguments completed: %v", got)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"/branch", "/branches"}, "/branch"},
+		{[]string{"/reset", "/retry"}, "/re"},
+		{[]string{"/help"}, "/help"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := CommonPrefix(test.values); got != test.want {
+			t.Errorf("CommonPrefix(%v) = %q, want %q", test.values, got, test.want)
+		}
+	}
+}
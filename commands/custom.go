@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/cogito/pkg/xlog"
+	"github.com/mudler/wiz/config"
+	"github.com/mudler/wiz/types"
+	"gopkg.in/yaml.v3"
+)
+
+// customCommandMeta is the optional YAML front matter of a custom command file
+type customCommandMeta struct {
+	Description string `yaml:"description"`
+	Usage       string `yaml:"usage"`
+}
+
+// Load returns a registry with the built-in commands and the custom commands
+// found in dirs. Custom commands never replace built-in ones.
+func Load(dirs ...string) *Registry {
+	r := Default()
+	builtin := map[string]bool{}
+	for _, cmd := range r.Commands() {
+		builtin[cmd.Name] = true
+	}
+
+	for _, dir := range dirs {
+		cmds, err := loadDir(dir)
+		if err != nil {
+			xlog.Warn("Failed to load custom commands", "dir", dir, "error", err)
+			continue
+		}
+		for _, cmd := range cmds {
+			if builtin[cmd.Name] {
+				xlog.Warn("Custom command shadows a built-in command, ignoring", "command", cmd.Name, "dir", dir)
+				continue
+			}
+			r.Register(cmd)
+		}
+	}
+
+	return r
+}
+
+// loadDir reads a custom command from each Markdown file in dir.
+// A missing directory is not an error.
+func loadDir(dir string) ([]Command, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := []Command{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, err := parseCustomCommand(strings.TrimSuffix(entry.Name(), ".md"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds, nil
+}
+
+// parseCustomCommand builds a command from a prompt template, optionally
+// preceded by a YAML front matter with its description and usage
+func parseCustomCommand(name string, data []byte) (Command, error) {
+	var meta customCommandMeta
+	body := data
+
+	if rest, ok := bytes.CutPrefix(data, []byte("---\n")); ok {
+		header, content, found := bytes.Cut(rest, []byte("\n---\n"))
+		if !found {
+			return Command{}, errors.New("unterminated front matter")
+		}
+		if err := yaml.Unmarshal(header, &meta); err != nil {
+			return Command{}, fmt.Errorf("invalid front matter: %w", err)
+		}
+		body = content
+	}
+
+	prompt := strings.TrimSpace(string(body))
+	if meta.Description == "" {
+		meta.Description = "Custom command"
+	}
+
+	return Command{
+		Name:        name,
+		Usage:       meta.Usage,
+		Description: meta.Description,
+		Run: func(env Env, args string) (Result, error) {
+			text, err := renderCustomCommand(env.Config, prompt, args)
+			if err != nil {
+				return Result{}, fmt.Errorf("failed to render %s%s: %w", Prefix, name, err)
+			}
+			return Result{Send: text}, nil
+		},
+	}, nil
+}
+
+// renderCustomCommand renders a custom command prompt with the given
+// arguments, without the secrets of the config
+func renderCustomCommand(cfg types.Config, prompt, args string) (string, error) {
+	data := config.TemplateData(cfg)
+	data.Args = args
+	data.Arguments = strings.Fields(args)
+
+	text, err := types.RenderTemplate(prompt, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(cfg.Redact(text)), nil
+}
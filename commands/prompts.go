@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mudler/cogito/pkg/xlog"
+	"github.com/mudler/wiz/chat"
+)
+
+// AddPromptsLater makes list give the MCP prompts to register, once they are
+// needed: for /help, or for a name no other command has. Until then, the
+// servers are only connected when the wizard uses them.
+func (r *Registry) AddPromptsLater(list func() ([]chat.PromptInfo, error)) {
+	r.pendingPrompts = list
+}
+
+// NeedsPrompts returns true if running line needs the MCP prompts, which
+// aren't listed yet
+func (r *Registry) NeedsPrompts(line string) bool {
+	if r.pendingPrompts == nil || !HasPrefix(line) {
+		return false
+	}
+	name, _ := Parse(line)
+	if name == "" {
+		return false
+	}
+	if name == "help" {
+		return true
+	}
+	_, ok := r.Lookup(name)
+	return !ok
+}
+
+// LoadPrompts lists the MCP prompts given to AddPromptsLater and registers them
+func (r *Registry) LoadPrompts() {
+	if r.pendingPrompts == nil {
+		return
+	}
+	prompts, err := r.pendingPrompts()
+	if err != nil {
+		xlog.Warn("Failed to list MCP prompts", "error", err)
+	}
+	r.AddPrompts(prompts)
+}
+
+// AddPrompts registers a command for each MCP prompt. Prompts never replace
+// the commands already registered, built-in or custom.
+func (r *Registry) AddPrompts(prompts []chat.PromptInfo) {
+	r.pendingPrompts = nil
+	for _, prompt := range prompts {
+		if _, ok := r.commands[prompt.Name]; ok {
+			xlog.Warn("MCP prompt shadows a command, ignoring", "prompt", prompt.Name, "server", prompt.Server)
+			continue
+		}
+		r.Register(promptCommand(prompt))
+	}
+}
+
+// promptCommand builds a command sending an MCP prompt. Arguments are given
+// in the order the prompt declares them, the last one takes the rest of the line.
+func promptCommand(prompt chat.PromptInfo) Command {
+	names := []string{}
+	for _, arg := range prompt.Arguments {
+		if arg.Required {
+			names = append(names, "<"+arg.Name+">")
+		} else {
+			names = append(names, "["+arg.Name+"]")
+		}
+	}
+
+	description := firstLine(prompt.Description)
+	if description == "" {
+		description = "MCP prompt"
+	}
+
+	return Command{
+		Name:        prompt.Name,
+		Usage:       strings.Join(names, " "),
+		Description: fmt.Sprintf("%s (%s)", description, prompt.Server),
+		Run: func(env Env, args string) (Result, error) {
+			values, err := promptArguments(prompt, args)
+			if err != nil {
+				return Result{}, err
+			}
+
+			text, err := env.Session.GetPrompt(prompt.Server, prompt.Name, values)
+			if err != nil {
+				return Result{}, err
+			}
+			return Result{Send: text}, nil
+		},
+	}
+}
+
+// promptArguments maps the words typed after a prompt command to its arguments
+func promptArguments(prompt chat.PromptInfo, args string) (map[string]string, error) {
+	values := map[string]string{}
+	n := len(prompt.Arguments)
+	if n == 0 {
+		return values, nil
+	}
+
+	words := strings.Fields(args)
+	if len(words) > n {
+		words = append(words[:n-1], strings.Join(words[n-1:], " "))
+	}
+	for i, word := range words {
+		values[prompt.Arguments[i].Name] = word
+	}
+
+	for _, arg := range prompt.Arguments {
+		if _, ok := values[arg.Name]; !ok && arg.Required {
+			return nil, fmt.Errorf("missing argument %s, usage: %s", arg.Name, usage(promptCommand(prompt)))
+		}
+	}
+	return values, nil
+}
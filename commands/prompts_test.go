@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mudler/wiz/chat"
+)
+
+func TestPromptsListedWhenNeeded(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Command{Name: "hello", Run: func(Env, string) (Result, error) {
+		return Result{Output: "hi"}, nil
+	}})
+
+	calls := 0
+	r.AddPromptsLater(func() ([]chat.PromptInfo, error) {
+		calls++
+		return []chat.PromptInfo{
+			{Name: "review", Server: "github"},
+			{Name: "hello", Server: "other"},
+		}, nil
+	})
+
+	if _, err := r.Execute(Env{}, "/hello"); err != nil || calls != 0 {
+		t.Fatalf("known command: err = %v, prompts listed %d times, want 0", err, calls)
+	}
+	if !r.NeedsPrompts("/help") || !r.NeedsPrompts("/review") {
+		t.Fatal("expected /help and unknown commands to need the prompts")
+	}
+
+	if _, err := r.Execute(Env{}, "/missing"); err == nil {
+		t.Fatal("expected an error for an unknown command")
+	}
+	if calls != 1 {
+		t.Fatalf("prompts listed %d times, want 1", calls)
+	}
+	if _, ok := r.Lookup("review"); !ok {
+		t.Fatal("prompt not registered")
+	}
+	if cmd, _ := r.Lookup("hello"); cmd.Description != "" {
+		t.Fatal("prompt replaced an existing command")
+	}
+
+	r.Execute(Env{}, "/missing")
+	if calls != 1 || r.NeedsPrompts("/help") {
+		t.Fatalf("prompts listed again: %d times", calls)
+	}
+}
+
+func TestIsCommandListsPrompts(t *testing.T) {
+	r := Default()
+	calls := 0
+	r.AddPromptsLater(func() ([]chat.PromptInfo, error) {
+		calls++
+		return []chat.PromptInfo{{Name: "review", Server: "github"}}, nil
+	})
+
+	if !r.IsCommand("/model") || calls != 0 {
+		t.Fatalf("built-in command: prompts listed %d times, want 0", calls)
+	}
+	if r.IsCommand("/") || calls != 0 {
+		t.Fatalf("bare prefix: prompts listed %d times, want 0", calls)
+	}
+	if !r.IsCommand("/review the last commit") || calls != 1 {
+		t.Fatalf("expected /review to be a command once the prompts are listed, listed %d times", calls)
+	}
+}
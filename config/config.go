@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mudler/wiz/types"
 
@@ -12,75 +14,240 @@ import (
 const defaultPrompt = `
 You are a Operative System terminal assistant that helps the user into automatizing common tasks, and can also do perform coding tasks.
 You will use the tools at your disposal to fullfill the user request, and, for instance run bash scripts to execute and automate things.
+When you suggest a command for the user to run, write it in a fenced code block so it can be inserted in the user's shell.
 
 Current directory: {{.CurrentDirectory}}
 Current user: {{.CurrentUser}}
+{{- if .OS}}
+Operating system: {{.OS}}
+{{- end}}
+{{- if .Shell}}
+Shell: {{.Shell}}
+{{- end}}
+{{- if .GitBranch}}
+Git branch: {{.GitBranch}} ({{.GitStatus}})
+{{- end}}
+{{- if .ProjectTypes}}
+Project type: {{join ", " .ProjectTypes}}
+{{- end}}
+{{- if .ShellHistory}}
+Recent shell history:
+{{- range .ShellHistory}}
+  {{.}}
+{{- end}}
+{{- end}}
+{{- if .CommandLine}}
+Command line being edited: {{.CommandLine}}
+{{- end}}
 `
 
-// configPaths returns the list of config file paths to try, in order of priority
+// configPaths returns the system and user config files, in the order they are
+// merged: later files override the values of earlier ones. The project config
+// is merged last, once trusted.
 func configPaths() []string {
 	var paths []string
 
-	// current directory, .wiz.yaml
-	cwd, err := os.Getwd()
-	if err == nil {
-		paths = append(paths, filepath.Join(cwd, ".wiz.yaml"))
-	}
+	// System
+	paths = append(paths, filepath.Join("/etc", "wiz", "config.yaml"))
 
-	// First priority: XDG config directory
+	// User: ~/.wiz.yaml, ~/.config/wiz/config.yaml, then the XDG config directory
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".wiz.yaml"))
+		paths = append(paths, filepath.Join(home, ".config", "wiz", "config.yaml"))
+	}
 	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
 		paths = append(paths, filepath.Join(xdgConfig, "wiz", "config.yaml"))
 	}
 
-	// Second priority: ~/.config/wiz/config.yaml
+	// The XDG config directory is often ~/.config
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// UserConfigPath returns the user config file written by 'wiz setup'
+func UserConfigPath() string {
+	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
+		return filepath.Join(xdgConfig, "wiz", "config.yaml")
+	}
 	if home, err := os.UserHomeDir(); err == nil {
-		paths = append(paths, filepath.Join(home, ".config", "wiz", "config.yaml"))
-		// Third priority: ~/.wiz.yaml
-		paths = append(paths, filepath.Join(home, ".wiz.yaml"))
+		return filepath.Join(home, ".config", "wiz", "config.yaml")
 	}
+	return ""
+}
 
-	paths = append(paths, filepath.Join("/etc", "wiz", "config.yaml"))
+// NeedsSetup returns true on first run: no system or user config file exists
+// and no model is set in the environment or by the "key=value" settings sets
+func NeedsSetup(sets ...string) bool {
+	if os.Getenv("MODEL") != "" {
+		return false
+	}
+	for _, set := range sets {
+		if key, value, _ := strings.Cut(set, "="); strings.TrimSpace(key) == "model" && strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	for _, path := range configPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// CommandDirs returns the directories custom commands are loaded from.
+// Later directories take precedence, so project commands override user ones.
+func CommandDirs() []string {
+	var dirs []string
 
-	return paths
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "wiz", "commands"))
+	}
+
+	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
+		dirs = append(dirs, filepath.Join(xdgConfig, "wiz", "commands"))
+	}
+
+	// .wiz/commands of the current directory, once trusted with the project config
+	if project := Project(); project != nil && project.Commands != "" && project.State == TrustTrusted {
+		dirs = append(dirs, project.Commands)
+	}
+
+	return dirs
 }
 
-// loadFromFile attempts to load config from the first existing config file
-func loadFromFile() types.Config {
-	var cfg types.Config
+// StateDir returns the directory where wiz keeps its state, like the input history
+func StateDir() string {
+	if xdgState := os.Getenv("XDG_STATE_HOME"); xdgState != "" {
+		return filepath.Join(xdgState, "wiz")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "wiz")
+	}
+	return ""
+}
+
+// envSettings are the environment variables overriding config values
+var envSettings = []struct{ key, env string }{
+	{"model", "MODEL"},
+	{"api_key", "API_KEY"},
+	{"base_url", "BASE_URL"},
+}
+
+// loadLayers merges the existing config files, the environment variables,
+// then the settings given as "key=value", into a single document. Files and
+// settings are checked before being merged, so problems point to them.
+func loadLayers(sets []string, origins Origins) (*yaml.Node, Problems) {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	problems := Problems{}
+
+	add := func(path string, data []byte, project bool) {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			problems = append(problems, parseProblems(path, err)...)
+			return
+		}
+		if len(doc.Content) == 0 {
+			// Empty file
+			return
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			problems = append(problems, Problem{Source: at(path, root), Message: "expected settings like 'model: gpt-4o'"})
+			return
+		}
+		if project {
+			problems = append(problems, restrictProject(path, root)...)
+		}
+		problems = append(problems, checkLayer(path, root)...)
+		merge(merged, root, "", path, origins)
+	}
 
 	for _, path := range configPaths() {
 		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
 		if err != nil {
+			problems = append(problems, Problem{Source: path, Message: err.Error()})
 			continue
 		}
+		add(path, data, false)
+	}
 
-		if err := yaml.Unmarshal(data, &cfg); err != nil {
-			continue
+	// A project config is only read once trusted, with its exact content
+	if project := Project(); project != nil {
+		switch project.State {
+		case TrustTrusted:
+			if project.data != nil {
+				add(project.Path, project.data, true)
+			}
+		case TrustIgnored:
+		default:
+			problems = append(problems, Problem{
+				Source:  strings.Join(project.Files(), ", "),
+				Message: "project config ignored until trusted, run 'wiz config trust' to use it",
+				Warning: true,
+			})
 		}
+	}
 
-		// Found and parsed a config file
-		break
+	for _, setting := range envSettings {
+		if value := os.Getenv(setting.env); value != "" {
+			merge(merged, valueNode(setting.key, stringNode(value)), "", "env "+setting.env, origins)
+		}
+	}
+
+	for _, set := range sets {
+		node, err := setNode(set)
+		if err != nil {
+			problems = append(problems, Problem{Source: "flag --set", Message: err.Error()})
+			continue
+		}
+		problems = append(problems, checkLayer("flag --set", node)...)
+		merge(merged, node, "", "flag --set", origins)
 	}
 
-	return cfg
+	return merged, problems
 }
 
-// Load loads the configuration from YAML file and environment variables.
-// Environment variables take precedence over YAML config.
-func Load() types.Config {
-	// Load from YAML file first
-	cfg := loadFromFile()
+// LoadKeyBindings returns the configured key bindings, for the shell
+// integration scripts. Unlike Load, it neither resolves secrets nor checks
+// the configuration, as it runs on every shell startup.
+func LoadKeyBindings(sets ...string) types.KeyBindings {
+	merged, _ := loadLayers(sets, Origins{})
 
-	// Override with environment variables if set
-	if model := os.Getenv("MODEL"); model != "" {
-		cfg.Model = model
-	}
-	if apiKey := os.Getenv("API_KEY"); apiKey != "" {
-		cfg.APIKey = apiKey
-	}
-	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
-		cfg.BaseURL = baseURL
+	var cfg struct {
+		KeyBindings types.KeyBindings `yaml:"keybindings"`
 	}
+	// Problems are left to Load, the defaults are used instead
+	_ = merged.Decode(&cfg)
+	return cfg.KeyBindings
+}
+
+// Load loads the configuration by merging, from lowest to highest priority,
+// the system, user and project config files, the environment variables and
+// the settings given as "key=value" on the command line. It also returns
+// where each value comes from, and the problems found in the configuration.
+func Load(sets ...string) (types.Config, Origins, Problems) {
+	var cfg types.Config
+	origins := Origins{}
+
+	merged, problems := loadLayers(sets, origins)
+	// Type errors were reported for the layer they come from
+	_ = merged.Decode(&cfg)
+
+	// Values can point to secrets kept out of the config file. A secret that
+	// can't be read is left empty: the problem is reported, wiz starts anyway.
+	problems = append(problems, resolveSecrets(&cfg, origins)...)
+	cfg.AddSecret(cfg.APIKey)
+	problems = append(problems, validate(cfg, origins)...)
 
 	if cfg.Prompt == "" {
 		cfg.Prompt = defaultPrompt
@@ -96,8 +263,40 @@ func Load() types.Config {
 	if cfg.AgentOptions.MaxRetries == 0 {
 		cfg.AgentOptions.MaxRetries = 3
 	}
+	// Set default TUI keybindings
+	if len(cfg.KeyBindings.Send) == 0 {
+		cfg.KeyBindings.Send = []string{"enter"}
+	}
+	if len(cfg.KeyBindings.Quit) == 0 {
+		cfg.KeyBindings.Quit = []string{"esc", "ctrl+c"}
+	}
+	if len(cfg.KeyBindings.UseCommand) == 0 {
+		cfg.KeyBindings.UseCommand = []string{"ctrl+y"}
+	}
+	if len(cfg.KeyBindings.InsertCommand) == 0 {
+		cfg.KeyBindings.InsertCommand = []string{"alt+y"}
+	}
+	if len(cfg.KeyBindings.Complete) == 0 {
+		cfg.KeyBindings.Complete = []string{"tab"}
+	}
+	if len(cfg.KeyBindings.Newline) == 0 {
+		cfg.KeyBindings.Newline = []string{"alt+enter", "ctrl+j"}
+	}
+	if len(cfg.KeyBindings.HistoryPrevious) == 0 {
+		cfg.KeyBindings.HistoryPrevious = []string{"up"}
+	}
+	if len(cfg.KeyBindings.HistoryNext) == 0 {
+		cfg.KeyBindings.HistoryNext = []string{"down"}
+	}
+	if len(cfg.KeyBindings.Search) == 0 {
+		cfg.KeyBindings.Search = []string{"ctrl+r"}
+	}
+	if len(cfg.KeyBindings.Navigate) == 0 {
+		cfg.KeyBindings.Navigate = []string{"pgup", "ctrl+g"}
+	}
+
 	// ForceReasoning defaults to false (zero value), which is intentional
 	// Users must explicitly enable it in config
 
-	return cfg
+	return cfg, origins, problems
 }
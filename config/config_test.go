@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNeedsSetup(t *testing.T) {
+	if _, err := os.Stat("/etc/wiz/config.yaml"); err == nil {
+		t.Skip("a system config exists")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("MODEL", "")
+
+	if !NeedsSetup() {
+		t.Fatal("expected setup without any config")
+	}
+	if !NeedsSetup("log_level=debug", "model=") {
+		t.Fatal("expected setup when --set gives no model")
+	}
+	if NeedsSetup("model=gpt-4o") {
+		t.Fatal("expected no setup when --set gives the model")
+	}
+
+	t.Setenv("MODEL", "gpt-4o")
+	if NeedsSetup() {
+		t.Fatal("expected no setup when MODEL is set")
+	}
+}
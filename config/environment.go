@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mudler/wiz/types"
+)
+
+// TemplateData returns the data used to render the prompt template and the
+// custom commands with the given config. The API key and the secrets are left
+// out, templates can't write them into the conversation.
+func TemplateData(c types.Config) types.TemplateData {
+	currentDirectory, err := os.Getwd()
+	if err != nil {
+		currentDirectory = ""
+	}
+	currentUser, err := user.Current()
+	if err != nil {
+		currentUser = &user.User{}
+	}
+
+	data := types.TemplateData{
+		Config:           c,
+		CurrentDirectory: currentDirectory,
+		CurrentUser:      currentUser.Username,
+	}
+	data.Config.APIKey = ""
+	data.Config.Secrets = nil
+
+	if c.Context.OS {
+		data.OS = detectOS()
+	}
+	if c.Context.Shell || c.Context.History > 0 {
+		data.Shell = detectShell(c)
+	}
+	if c.Context.Git && currentDirectory != "" {
+		data.GitBranch, data.GitStatus = gitInfo(currentDirectory)
+	}
+	if c.Context.Project && currentDirectory != "" {
+		data.ProjectTypes = detectProjectTypes(currentDirectory)
+	}
+	if c.Context.History > 0 {
+		data.ShellHistory = shellHistory(data.Shell, c.Context.History, c.Redact)
+	}
+	if c.Context.CommandLine {
+		data.CommandLine = c.CommandLine
+	}
+
+	return data
+}
+
+// SystemPrompt renders the prompt template of the config, or returns an
+// empty prompt if it can't be rendered. Secrets the template reaches anyway,
+// like the MCP server headers, are redacted.
+func SystemPrompt(c types.Config) string {
+	prompt, err := types.RenderTemplate(c.Prompt, TemplateData(c))
+	if err != nil {
+		return ""
+	}
+	return c.Redact(prompt)
+}
+
+// commandTimeout bounds the external commands run to gather context
+const commandTimeout = 2 * time.Second
+
+// projectMarkers maps files found in a project root to the project type they denote
+var projectMarkers = []struct {
+	file, kind string
+}{
+	{"go.mod", "Go"},
+	{"package.json", "Node.js"},
+	{"Cargo.toml", "Rust"},
+	{"pyproject.toml", "Python"},
+	{"requirements.txt", "Python"},
+	{"setup.py", "Python"},
+	{"Gemfile", "Ruby"},
+	{"pom.xml", "Java (Maven)"},
+	{"build.gradle", "Java (Gradle)"},
+	{"build.gradle.kts", "Kotlin (Gradle)"},
+	{"composer.json", "PHP"},
+	{"mix.exs", "Elixir"},
+	{"CMakeLists.txt", "C/C++ (CMake)"},
+	{"Makefile", "Make"},
+	{"Dockerfile", "Docker"},
+	{"flake.nix", "Nix"},
+}
+
+// detectOS returns a human readable description of the operating system
+func detectOS() string {
+	if runtime.GOOS == "linux" {
+		if name := osReleaseName(); name != "" {
+			return fmt.Sprintf("%s (%s)", name, runtime.GOARCH)
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		if version := runCommand("", "sw_vers", "-productVersion"); version != "" {
+			return fmt.Sprintf("macOS %s (%s)", version, runtime.GOARCH)
+		}
+	}
+	return fmt.Sprintf("%s (%s)", runtime.GOOS, runtime.GOARCH)
+}
+
+// osReleaseName returns the distribution name from /etc/os-release
+func osReleaseName() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// detectShell returns the name of the shell that summoned wiz, as given by
+// the shell widget, or of the login shell otherwise
+func detectShell(cfg types.Config) string {
+	if cfg.Shell != "" {
+		return cfg.Shell
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return ""
+	}
+	return filepath.Base(shell)
+}
+
+// gitInfo returns the current branch and a summary of the working tree status
+func gitInfo(dir string) (string, string) {
+	branch := runCommand(dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "" {
+		return "", ""
+	}
+
+	status := runCommand(dir, "git", "status", "--porcelain")
+	if status == "" {
+		return branch, "clean"
+	}
+
+	var staged, modified, untracked int
+	for _, line := range strings.Split(status, "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		switch {
+		case line[:2] == "??":
+			untracked++
+		default:
+			if line[0] != ' ' {
+				staged++
+			}
+			if line[1] != ' ' {
+				modified++
+			}
+		}
+	}
+
+	return branch, fmt.Sprintf("%d staged, %d modified, %d untracked", staged, modified, untracked)
+}
+
+// detectProjectTypes returns the kinds of project found in dir
+func detectProjectTypes(dir string) []string {
+	kinds := []string{}
+	seen := map[string]bool{}
+	for _, marker := range projectMarkers {
+		if seen[marker.kind] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, marker.file)); err == nil {
+			kinds = append(kinds, marker.kind)
+			seen[marker.kind] = true
+		}
+	}
+	return kinds
+}
+
+// shellHistory returns the last n commands from the shell history file,
+// passed through redact as they may hold secrets typed in commands
+func shellHistory(shell string, n int, redact func(string) string) []string {
+	path := historyFile(shell)
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	history := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		switch shell {
+		case "zsh":
+			// Extended history format: ": 1700000000:0;command"
+			if strings.HasPrefix(line, ": ") {
+				if _, cmd, ok := strings.Cut(line, ";"); ok {
+					line = cmd
+				}
+			}
+		case "fish":
+			cmd, ok := strings.CutPrefix(line, "- cmd: ")
+			if !ok {
+				continue
+			}
+			line = cmd
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		history = append(history, redact(line))
+	}
+
+	if len(history) > n {
+		history = history[len(history)-n:]
+	}
+	return history
+}
+
+// historyFile returns the path of the history file for the given shell
+func historyFile(shell string) string {
+	if histFile := os.Getenv("HISTFILE"); histFile != "" {
+		return histFile
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	switch shell {
+	case "zsh":
+		return filepath.Join(home, ".zsh_history")
+	case "bash":
+		return filepath.Join(home, ".bash_history")
+	case "fish":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "fish", "fish_history")
+	default:
+		return ""
+	}
+}
+
+// runCommand runs a command in dir and returns its output without trailing
+// newlines, or an empty string on failure
+func runCommand(dir, name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(out), "\n")
+}
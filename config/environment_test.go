@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mudler/wiz/types"
+)
+
+func TestShellHistoryRedacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history := ": 1700000000:0;ls\n: 1700000001:0;curl -H 'Authorization: Bearer sk-secret-token' api\n: 1700000002:0;git status\n"
+	if err := os.WriteFile(path, []byte(history), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HISTFILE", path)
+
+	cfg := types.Config{}
+	cfg.AddSecret("sk-secret-token")
+	got := shellHistory("zsh", 2, cfg.Redact)
+	want := []string{"curl -H 'Authorization: Bearer [REDACTED]' api", "git status"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("shellHistory = %q, want %q", got, want)
+	}
+}
+
+func TestDetectShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	if got := detectShell(types.Config{Shell: "fish"}); got != "fish" {
+		t.Errorf("detectShell = %q, want the shell given by the widget", got)
+	}
+	if got := detectShell(types.Config{}); got != "zsh" {
+		t.Errorf("detectShell = %q, want the login shell", got)
+	}
+}
+
+func TestTemplateDataHidesSecrets(t *testing.T) {
+	cfg := types.Config{
+		APIKey:     "sk-api-key-value",
+		Prompt:     "key={{.Config.APIKey}} secrets={{.Config.Secrets}} header={{(index .Config.MCPServers \"github\").Headers.Authorization}}",
+		MCPServers: map[string]types.MCPServer{"github": {URL: "https://example.com/mcp", Headers: map[string]string{"Authorization": "Bearer ghp-header-token"}}},
+	}
+	cfg.AddSecret(cfg.APIKey)
+	cfg.AddSecret("ghp-header-token")
+
+	data := TemplateData(cfg)
+	if data.Config.APIKey != "" || data.Config.Secrets != nil {
+		t.Fatalf("secrets in the template data: %q %q", data.Config.APIKey, data.Config.Secrets)
+	}
+
+	want := "key= secrets=[] header=Bearer [REDACTED]"
+	if got := SystemPrompt(cfg); got != want {
+		t.Fatalf("SystemPrompt = %q, want %q", got, want)
+	}
+}
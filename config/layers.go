@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origins tells where each value of the config comes from, by dotted path
+// like "mcp_servers.github.url": a file and line, an environment variable or
+// a flag. Values missing from it are defaults.
+type Origins map[string]string
+
+// Of returns the origin of the value at path. For a section, it returns the
+// origin of one of its values.
+func (o Origins) Of(path string) string {
+	if origin, ok := o[path]; ok {
+		return origin
+	}
+
+	paths := []string{}
+	for p := range o {
+		if strings.HasPrefix(p, path+".") {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) > 0 {
+		sort.Strings(paths)
+		return o[paths[0]]
+	}
+	return "default"
+}
+
+// forget removes the origins of the value at path and of the values below it
+func (o Origins) forget(path string) {
+	for p := range o {
+		if p == path || strings.HasPrefix(p, path+".") {
+			delete(o, p)
+		}
+	}
+}
+
+// record sets the origin of the values of node, at path and below
+func (o Origins) record(path string, node *yaml.Node, source string) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			o.record(join(path, node.Content[i].Value), node.Content[i+1], source)
+		}
+		return
+	}
+	if node.Line > 0 {
+		o[path] = fmt.Sprintf("%s:%d", source, node.Line)
+	} else {
+		o[path] = source
+	}
+}
+
+// wholeEntries are the maps whose entries replace each other as a whole
+// instead of being merged: a project redefining an MCP server doesn't inherit
+// the arguments or environment of the user's server with the same name.
+var wholeEntries = map[string]bool{
+	"mcp_servers": true,
+}
+
+// merge merges the mapping src into the mapping dst, recording the origin of
+// the values taken from src. Mappings are merged key by key, other values,
+// lists included, replace the ones of dst. A null value removes the key.
+func merge(dst, src *yaml.Node, path, source string, origins Origins) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		keyPath := join(path, key.Value)
+
+		j := indexOf(dst, key.Value)
+		if value.Tag == "!!null" {
+			if j >= 0 {
+				dst.Content = append(dst.Content[:j], dst.Content[j+2:]...)
+			}
+			origins.forget(keyPath)
+			continue
+		}
+
+		if j >= 0 && !wholeEntries[path] && dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			merge(dst.Content[j+1], value, keyPath, source, origins)
+			continue
+		}
+
+		origins.forget(keyPath)
+		origins.record(keyPath, value, source)
+		if j >= 0 {
+			dst.Content[j+1] = value
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+// indexOf returns the index of key in a mapping node, or -1
+func indexOf(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// join appends a key to a dotted path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// setNode builds the mapping setting a value given as "path.to.key=value",
+// the value being parsed as YAML
+func setNode(set string) (*yaml.Node, error) {
+	path, text, ok := strings.Cut(set, "=")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("invalid setting %q, expected key=value", set)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", path, err)
+	}
+	value := stringNode("")
+	if len(doc.Content) > 0 {
+		value = doc.Content[0]
+	}
+	// Not from a file, lines are meaningless
+	clearLines(value)
+
+	return valueNode(path, value), nil
+}
+
+// valueNode builds the mapping setting value at a dotted path
+func valueNode(path string, value *yaml.Node) *yaml.Node {
+	keys := strings.Split(path, ".")
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: []*yaml.Node{stringNode(keys[i]), value},
+		}
+	}
+	return value
+}
+
+// stringNode builds a string scalar
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// clearLines removes the positions of a node and its children
+func clearLines(node *yaml.Node) {
+	node.Line, node.Column = 0, 0
+	for _, child := range node.Content {
+		clearLines(child)
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mudler/wiz/types"
+)
+
+// secretPattern matches the secret references of config values:
+// ${env:NAME}, ${file:path} and ${cmd:command}
+var secretPattern = regexp.MustCompile(`\$\{(env|file|cmd):([^}]*)\}`)
+
+// resolveSecret returns the secret a reference points to
+func resolveSecret(kind, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return value, nil
+	case "file":
+		if rest, ok := strings.CutPrefix(ref, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			ref = filepath.Join(home, rest)
+		}
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "cmd":
+		// Commands like 'pass' may ask for a passphrase on the terminal
+		cmd := exec.Command("sh", "-c", ref)
+		cmd.Stdin = os.Stdin
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%q failed: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", fmt.Errorf("unknown secret reference %q", kind)
+}
+
+// ResolveSecrets returns value with its secret references replaced by the
+// secrets they point to
+func ResolveSecrets(value string) (string, error) {
+	var firstErr error
+	resolved := secretPattern.ReplaceAllStringFunc(value, func(match string) string {
+		parts := secretPattern.FindStringSubmatch(match)
+		secret, err := resolveSecret(parts[1], parts[2])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return secret
+	})
+	return resolved, firstErr
+}
+
+// resolveSecrets replaces the secret references in the values that may hold
+// secrets, and records the secrets so they get redacted
+func resolveSecrets(cfg *types.Config, origins Origins) Problems {
+	problems := Problems{}
+	resolve := func(field, value string) string {
+		return secretPattern.ReplaceAllStringFunc(value, func(match string) string {
+			parts := secretPattern.FindStringSubmatch(match)
+			secret, err := resolveSecret(parts[1], parts[2])
+			if err != nil {
+				problems = append(problems, Problem{
+					Source:  origins.Of(field),
+					Path:    field,
+					Message: "failed to read secret: " + err.Error(),
+					Warning: true,
+				})
+				return ""
+			}
+			cfg.AddSecret(secret)
+			return secret
+		})
+	}
+
+	cfg.APIKey = resolve("api_key", cfg.APIKey)
+	cfg.BaseURL = resolve("base_url", cfg.BaseURL)
+
+	for name, server := range cfg.MCPServers {
+		prefix := "mcp_servers." + name
+		for i, arg := range server.Args {
+			server.Args[i] = resolve(fmt.Sprintf("%s.args[%d]", prefix, i), arg)
+		}
+		for k, v := range server.Env {
+			server.Env[k] = resolve(prefix+".env."+k, v)
+		}
+		for k, v := range server.Headers {
+			server.Headers[k] = resolve(prefix+".headers."+k, v)
+		}
+		server.URL = resolve(prefix+".url", server.URL)
+		cfg.MCPServers[name] = server
+	}
+
+	return problems
+}
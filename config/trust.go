@@ -0,0 +1,324 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Trust states of a project config
+const (
+	TrustUnknown = "unknown" // Never decided
+	TrustChanged = "changed" // Changed since it was trusted or ignored
+	TrustTrusted = "trusted"
+	TrustIgnored = "ignored"
+)
+
+// userOnlyKeys can't be set by project configs, even trusted: a repository
+// could otherwise send the prompts, and the API key, to its own server
+var userOnlyKeys = []string{"api_key", "base_url"}
+
+// trustDecision is the decision about a project config, pinned to its content
+type trustDecision struct {
+	SHA256  string `yaml:"sha256"`
+	Trusted bool   `yaml:"trusted"`
+}
+
+// ProjectConfig is the configuration of the current directory: its .wiz.yaml
+// and its custom commands in .wiz/commands. It is only used once trusted, as
+// it can start MCP servers and change what commands ask the model.
+type ProjectConfig struct {
+	Path     string // .wiz.yaml, which may not exist
+	Commands string // Directory of the custom commands, "" if there is none
+	State    string
+	data     []byte            // Content of .wiz.yaml, nil if it doesn't exist
+	commands map[string][]byte // Content of the custom command files, by name
+}
+
+// trustFile returns the file keeping the trust decisions
+func trustFile() string {
+	return filepath.Join(StateDir(), "trusted.yaml")
+}
+
+// loadTrust reads the trust decisions, by config path
+func loadTrust() map[string]trustDecision {
+	decisions := map[string]trustDecision{}
+	data, err := os.ReadFile(trustFile())
+	if err != nil {
+		return decisions
+	}
+	if err := yaml.Unmarshal(data, &decisions); err != nil {
+		return map[string]trustDecision{}
+	}
+	return decisions
+}
+
+// hash returns the SHA-256 of the project config content: .wiz.yaml, then
+// the name and content of each custom command
+func (p *ProjectConfig) hash() string {
+	h := sha256.New()
+	h.Write(p.data)
+
+	names := []string{}
+	for name := range p.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "\x00%s\x00%d\x00", name, len(p.commands[name]))
+		h.Write(p.commands[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// trustState returns whether the project config at path was trusted with
+// the content hashed by sum
+func trustState(path, sum string) string {
+	decision, ok := loadTrust()[path]
+	switch {
+	case !ok:
+		return TrustUnknown
+	case decision.SHA256 != sum:
+		return TrustChanged
+	case decision.Trusted:
+		return TrustTrusted
+	default:
+		return TrustIgnored
+	}
+}
+
+// projectPath returns the path of the project config, in the current
+// directory, or "" if it is also a user config, like ~/.wiz.yaml when run
+// from home
+func projectPath() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(cwd, ".wiz.yaml")
+	if slices.Contains(configPaths(), path) {
+		return ""
+	}
+	return path
+}
+
+// Project returns the config of the current directory, or nil if there is none
+func Project() *ProjectConfig {
+	path := projectPath()
+	if path == "" {
+		return nil
+	}
+
+	p := &ProjectConfig{Path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		p.data = data
+	}
+	dir := filepath.Join(filepath.Dir(path), ".wiz", "commands")
+	if commands := readCommands(dir); len(commands) > 0 {
+		p.Commands = dir
+		p.commands = commands
+	}
+	if p.data == nil && p.commands == nil {
+		return nil
+	}
+
+	p.State = trustState(path, p.hash())
+	return p
+}
+
+// readCommands returns the content of the custom command files in dir, by name
+func readCommands(dir string) map[string][]byte {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	commands := map[string][]byte{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, entry.Name())); err == nil {
+			commands[entry.Name()] = data
+		}
+	}
+	return commands
+}
+
+// Files returns the paths making up the project config
+func (p *ProjectConfig) Files() []string {
+	files := []string{}
+	if p.data != nil {
+		files = append(files, p.Path)
+	}
+	if p.Commands != "" {
+		files = append(files, p.Commands)
+	}
+	return files
+}
+
+// Undecided returns true if the user has to decide whether to trust the config
+func (p *ProjectConfig) Undecided() bool {
+	return p.State == TrustUnknown || p.State == TrustChanged
+}
+
+// Decide records whether the config is trusted, for its current content
+func (p *ProjectConfig) Decide(trusted bool) error {
+	decisions := loadTrust()
+	decisions[p.Path] = trustDecision{SHA256: p.hash(), Trusted: trusted}
+
+	data, err := yaml.Marshal(decisions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(trustFile()), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(trustFile(), data, 0600); err != nil {
+		return fmt.Errorf("failed to record trust decision: %w", err)
+	}
+
+	p.State = TrustIgnored
+	if trusted {
+		p.State = TrustTrusted
+	}
+	return nil
+}
+
+// Summary describes what the config sets, to decide whether to trust it
+func (p *ProjectConfig) Summary() string {
+	var settings struct {
+		MCPServers map[string]struct {
+			Command string   `yaml:"command"`
+			Args    []string `yaml:"args"`
+			URL     string   `yaml:"url"`
+		} `yaml:"mcp_servers"`
+	}
+	var keys map[string]any
+	if err := yaml.Unmarshal(p.data, &keys); err != nil {
+		return "The file can't be parsed: " + err.Error()
+	}
+	_ = yaml.Unmarshal(p.data, &settings)
+
+	names := []string{}
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	if len(names) > 0 {
+		sb.WriteString("It sets: " + strings.Join(names, ", ") + "\n")
+	}
+
+	servers := []string{}
+	for name := range settings.MCPServers {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+	if len(servers) > 0 {
+		sb.WriteString("It starts these MCP servers:\n")
+	}
+	for _, name := range servers {
+		server := settings.MCPServers[name]
+		target := server.URL
+		if server.Command != "" {
+			target = strings.Join(append([]string{server.Command}, server.Args...), " ")
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", name, target))
+	}
+
+	// Shown even though they are ignored, as the config may rely on them
+	var doc yaml.Node
+	_ = yaml.Unmarshal(p.data, &doc)
+	references := []string{}
+	walkScalars(&doc, "", func(path string, node *yaml.Node) {
+		for _, ref := range secretPattern.FindAllString(node.Value, -1) {
+			references = append(references, fmt.Sprintf("  %s: %s", path, ref))
+		}
+	})
+	if len(references) > 0 {
+		sb.WriteString("It uses these secret references, which project configs can't, so they are ignored:\n")
+		sb.WriteString(strings.Join(references, "\n") + "\n")
+	}
+
+	commands := []string{}
+	for name := range p.commands {
+		commands = append(commands, "/"+strings.TrimSuffix(name, ".md"))
+	}
+	sort.Strings(commands)
+	if len(commands) > 0 {
+		sb.WriteString("It adds these commands, overriding yours with the same name: " + strings.Join(commands, ", ") + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// restrictProject removes what project configs can't set: the user only
+// keys, and secret references, which would read the user's environment and
+// files or run commands
+func restrictProject(path string, root *yaml.Node) Problems {
+	problems := Problems{}
+	for _, key := range userOnlyKeys {
+		if i := indexOf(root, key); i >= 0 {
+			problems = append(problems, Problem{
+				Source:  at(path, root.Content[i]),
+				Path:    key,
+				Message: "can only be set in the user or system config, ignored",
+				Warning: true,
+			})
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		}
+	}
+
+	walkScalars(root, "", func(key string, node *yaml.Node) {
+		if secretPattern.MatchString(node.Value) {
+			problems = append(problems, Problem{
+				Source:  at(path, node),
+				Path:    key,
+				Message: "secret references can only be used in the user or system config, ignored",
+				Warning: true,
+			})
+			node.Value = ""
+		}
+	})
+	return problems
+}
+
+// walkScalars calls fn with each scalar value under node and its dotted path
+func walkScalars(node *yaml.Node, path string, fn func(path string, node *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkScalars(child, path, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkScalars(node.Content[i+1], join(path, node.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			walkScalars(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case yaml.ScalarNode:
+		fn(path, node)
+	}
+}
+
+// errNoProject is returned when there is no project config to trust
+var errNoProject = errors.New("no project config (.wiz.yaml or .wiz/commands) in the current directory")
+
+// TrustProject records a decision about the project config without asking
+func TrustProject(trusted bool) (*ProjectConfig, error) {
+	project := Project()
+	if project == nil {
+		return nil, errNoProject
+	}
+	return project, project.Decide(trusted)
+}
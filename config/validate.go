@@ -0,0 +1,285 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/wiz/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an issue found in the configuration
+type Problem struct {
+	Source  string // Where the value comes from, e.g. "/home/user/.wiz.yaml:3"
+	Path    string // Dotted path of the value, e.g. "mcp_servers.github.url"
+	Message string
+	Warning bool // wiz can still run, e.g. an MCP server that can't start
+}
+
+func (p Problem) String() string {
+	parts := []string{}
+	if p.Source != "" {
+		parts = append(parts, p.Source)
+	}
+	if p.Path != "" {
+		parts = append(parts, p.Path)
+	}
+	return strings.Join(append(parts, p.Message), ": ")
+}
+
+// Problems are the issues found in the configuration
+type Problems []Problem
+
+// Errors returns the problems wiz can't run with
+func (p Problems) Errors() Problems {
+	errs := Problems{}
+	for _, problem := range p {
+		if !problem.Warning {
+			errs = append(errs, problem)
+		}
+	}
+	return errs
+}
+
+// Warnings returns the problems wiz can run with
+func (p Problems) Warnings() Problems {
+	warnings := Problems{}
+	for _, problem := range p {
+		if problem.Warning {
+			warnings = append(warnings, problem)
+		}
+	}
+	return warnings
+}
+
+// Err returns an error listing the errors, or nil if there is none
+func (p Problems) Err() error {
+	errs := p.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	lines := []string{"invalid configuration:"}
+	for _, problem := range errs {
+		lines = append(lines, "  "+problem.String())
+	}
+	lines = append(lines, "Run 'wiz config validate' to check it.")
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// yamlLinePattern matches the line numbers in the errors of the YAML parser
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// parseProblems converts the errors of the YAML parser, syntax or type errors,
+// into problems pointing to the line in source
+func parseProblems(source string, err error) Problems {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	problems := Problems{}
+	for _, message := range messages {
+		problem := Problem{Source: source, Message: strings.TrimPrefix(message, "yaml: ")}
+		if m := yamlLinePattern.FindStringSubmatch(message); m != nil && m[1] != "0" {
+			problem.Source = source + ":" + m[1]
+			problem.Message = m[2]
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// checkLayer checks a config file, or the settings from the command line,
+// before it is merged: unknown fields and values of the wrong type
+func checkLayer(source string, node *yaml.Node) Problems {
+	problems := checkFields(source, node, reflect.TypeOf(types.Config{}), "")
+
+	var cfg types.Config
+	if err := node.Decode(&cfg); err != nil {
+		problems = append(problems, parseProblems(source, err)...)
+	}
+	return problems
+}
+
+// checkFields reports the keys of node that aren't fields of t
+func checkFields(source string, node *yaml.Node, t reflect.Type, path string) Problems {
+	problems := Problems{}
+	if node.Tag == "!!null" {
+		return problems
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return problems
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown field %q", key.Value)
+				if suggestion := closest(key.Value, fields); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				problems = append(problems, Problem{Source: at(source, key), Path: path, Message: message})
+				continue
+			}
+			problems = append(problems, checkFields(source, value, field, join(path, key.Value))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return problems
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, checkFields(source, node.Content[i+1], t.Elem(), join(path, node.Content[i].Value))...)
+		}
+	}
+	return problems
+}
+
+// yamlFields returns the types of the fields of a struct, by YAML key
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closest returns the field name closest to a misspelled one, if close enough
+func closest(name string, fields map[string]reflect.Type) string {
+	names := []string{}
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, field := range names {
+		if d := distance(name, field); d < bestDistance {
+			best, bestDistance = field, d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// at returns the position of a node in source
+func at(source string, node *yaml.Node) string {
+	if node.Line == 0 {
+		return source
+	}
+	return source + ":" + strconv.Itoa(node.Line)
+}
+
+// validate checks the values of the merged configuration
+func validate(cfg types.Config, origins Origins) Problems {
+	problems := Problems{}
+	add := func(path, message string, warning bool) {
+		// Messages may quote values holding secrets, like URLs with tokens
+		problems = append(problems, Problem{Source: origins.Of(path), Path: path, Message: cfg.Redact(message), Warning: warning})
+	}
+
+	if cfg.Model == "" {
+		problems = append(problems, Problem{Path: "model", Message: "no model set, run 'wiz setup', add one to ~/.config/wiz/config.yaml or set MODEL"})
+	}
+	if cfg.BaseURL != "" {
+		if err := checkURL(cfg.BaseURL); err != nil {
+			add("base_url", err.Error(), false)
+		}
+	}
+
+	switch strings.ToLower(cfg.LogLevel) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		add("log_level", fmt.Sprintf("unknown level %q, expected debug, info, warn or error", cfg.LogLevel), false)
+	}
+	switch cfg.Tmux.Mode {
+	case "", "popup", "split":
+	default:
+		add("tmux.mode", fmt.Sprintf("unknown mode %q, expected popup or split", cfg.Tmux.Mode), false)
+	}
+
+	names := []string{}
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		server := cfg.MCPServers[name]
+		path := "mcp_servers." + name
+
+		switch {
+		case server.Command == "" && server.URL == "":
+			add(path, "set either command or url", false)
+		case server.Command != "" && server.URL != "":
+			add(path, "set either command or url, not both", false)
+		case server.URL != "":
+			if err := checkURL(server.URL); err != nil {
+				add(path+".url", err.Error(), false)
+			}
+			switch server.Transport {
+			case "", "streamable-http", "sse":
+			default:
+				add(path+".transport", fmt.Sprintf("unknown transport %q, expected streamable-http or sse", server.Transport), false)
+			}
+		default:
+			// The server may be installed later, wiz goes on without it meanwhile
+			if _, err := exec.LookPath(server.Command); err != nil {
+				add(path+".command", fmt.Sprintf("command %q not found", server.Command), true)
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkURL checks that a value is an HTTP URL
+func checkURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q, expected http(s)://host/...", value)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
@@ -15,6 +16,7 @@ import (
 	"github.com/mudler/wiz/config"
 	"github.com/mudler/wiz/internal"
 	"github.com/mudler/wiz/mcp"
+	"github.com/mudler/wiz/types"
 )
 
 // parseHeight parses a height string like "40%" or "20"
@@ -37,13 +39,66 @@ func parseHeight(s string) int {
 	return height
 }
 
+// settings collects the repeated --set flags
+type settings []string
+
+func (s *settings) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *settings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// tmuxArgs returns the flags to forward to the wiz running in tmux. The
+// cursor is already converted to runes.
+func tmuxArgs(shell, buffer string, cursor int, fix, explain bool, sets settings) []string {
+	args := []string{"--buffer", buffer, "--cursor", strconv.Itoa(cursor)}
+	if shell != "" {
+		args = append(args, "--shell", shell)
+	}
+	for _, set := range sets {
+		args = append(args, "--set", set)
+	}
+	if fix {
+		args = append(args, "--fix")
+	}
+	if explain {
+		args = append(args, "--explain")
+	}
+	return args
+}
+
+// writeOutput gives the command for the shell back, either on stdout or in the given file
+func writeOutput(path, output string) error {
+	if path == "" {
+		fmt.Print(output)
+		return nil
+	}
+	return os.WriteFile(path, []byte(output), 0600)
+}
+
 func main() {
 	// Parse command line arguments
 	heightFlag := flag.String("height", "", "Height of the TUI (e.g., '40%' or '20')")
-	initFlag := flag.String("init", "", "Output shell integration script (zsh, bash, or fish)")
+	initFlag := flag.String("init", "", "Output shell integration script ("+strings.Join(cmd.SupportedShells, ", ")+")")
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 	tmuxFlag := flag.Bool("tmux", false, "Run in tmux popup (auto-detected if in tmux)")
 	noTmuxFlag := flag.Bool("no-tmux", false, "Disable tmux popup even when in tmux")
+	bufferFlag := flag.String("buffer", "", "Command line being edited in the shell, used as context")
+	cursorFlag := flag.Int("cursor", -1, "Cursor position in the command line passed with --buffer")
+	shellFlag := flag.String("shell", "", "Shell summoning wiz, set by the shell widgets (defaults to the login shell)")
+	cursorUnitFlag := flag.String("cursor-unit", "rune", "Unit of the --cursor position ("+strings.Join(cmd.CursorUnits, ", ")+")")
+	fixFlag := flag.Bool("fix", false, "Explain and fix the last failed shell command")
+	explainFlag := flag.Bool("explain", false, "Explain the command line passed with --buffer")
+	outputFlag := flag.String("output", "", "Write the command returned to the shell to this file instead of stdout")
+	var setFlags settings
+	flag.Var(&setFlags, "set", "Override a config value, e.g. 'model=gpt-4o' or 'mcp_servers.github=null' (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: wiz [flags]\n       wiz export [flags] <file.json>\n       wiz mcp list\n       wiz mcp serve [flags]\n       wiz config show [--origin]\n       wiz config validate\n       wiz config trust|untrust\n       wiz setup\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	// Handle version flag
@@ -52,36 +107,110 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Handle init command
+	// Handle init command. It runs on every shell startup, so it only reads
+	// the key bindings: no secrets to resolve or MCP servers to check.
 	if *initFlag != "" {
-		script := cmd.GetInitScript(*initFlag)
-		if script == "" {
-			fmt.Fprintf(os.Stderr, "Unknown shell: %s. Supported: zsh, bash, fish\n", *initFlag)
+		script, err := cmd.GetInitScript(*initFlag, config.LoadKeyBindings(setFlags...))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Print(script)
 		os.Exit(0)
 	}
 
+	// A project config can start MCP servers, it is only used once trusted.
+	// Don't ask from subcommands.
+	if flag.NArg() == 0 {
+		if err := cmd.AskTrust(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		// On first run, set up the provider rather than failing on the first message
+		if config.NeedsSetup(setFlags...) {
+			if err := cmd.RunSetup(true); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+
+	cfg, origins, problems := config.Load(setFlags...)
+
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "error"
+	}
+
+	logger := xlog.NewLogger(xlog.LogLevel(cfg.LogLevel), os.Getenv("LOG_FORMAT"))
+	xlog.SetLogger(slog.New(types.RedactHandler(logger.Handler(), cfg.Redact)))
+
+	// Subcommands
+	if flag.NArg() > 0 {
+		var err error
+		switch flag.Arg(0) {
+		case "export":
+			err = cmd.RunExport(flag.Args()[1:])
+		case "mcp":
+			err = cmd.RunMCP(context.Background(), cfg, flag.Args()[1:])
+		case "setup":
+			err = cmd.RunSetup(false)
+		case "config":
+			err = cmd.RunConfig(cfg, origins, problems, flag.Args()[1:])
+		default:
+			err = fmt.Errorf("unknown command %q", flag.Arg(0))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	cursor, err := cmd.CursorIndex(*bufferFlag, *cursorFlag, *cursorUnitFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.CommandLine = *bufferFlag
+	cfg.CommandLineCursor = cursor
+	cfg.Shell = *shellFlag
+
+	// Don't start with a broken config, the first message would fail
+	for _, warning := range problems.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if err := problems.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// In fix and explain modes, the conversation starts with a question about the shell
+	query := ""
+	switch {
+	case *fixFlag:
+		query, err = cmd.FixQuery()
+	case *explainFlag:
+		query, err = cmd.ExplainQuery(cfg.CommandLine)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigs, syscall.SIGTERM)
+	if *heightFlag != "" {
+		// The CLI handles interrupts itself, to cancel requests without exiting
+		signal.Notify(sigs, syscall.SIGINT)
+	}
 
 	go func() {
 		<-sigs
 		cancel()
 	}()
 
-	cfg := config.Load()
-
-	if cfg.LogLevel == "" {
-		cfg.LogLevel = "error"
-	}
-
-	xlog.SetLogger(xlog.NewLogger(xlog.LogLevel(cfg.LogLevel), os.Getenv("LOG_FORMAT")))
-
 	transports, err := mcp.StartTransports(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting MCP servers: %v\n", err)
@@ -95,22 +224,26 @@ func main() {
 		// Check if we should use tmux popup
 		useTmux := *tmuxFlag || (cmd.IsInTmux() && !*noTmuxFlag)
 
+		var output string
 		if useTmux && cmd.IsInTmux() {
-			// Run in tmux split pane (like fzf-tmux -d)
-			if err := cmd.RunTmuxSplit(*heightFlag); err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
-			}
+			// Run in a tmux popup or split pane (like fzf-tmux), forwarding the shell context
+			output, err = cmd.RunTmux(cfg.Tmux, *heightFlag, tmuxArgs(cfg.Shell, cfg.CommandLine, cfg.CommandLineCursor, *fixFlag, *explainFlag, setFlags)...)
 		} else {
 			// TUI mode
-			if err := cmd.RunTUI(ctx, cfg, height, transports...); err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
-			}
+			output, err = cmd.RunTUI(ctx, cfg, height, query, transports...)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := writeOutput(*outputFlag, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 	} else {
 		// CLI mode (original behavior)
-		if err := cmd.RunCLI(ctx, cfg, transports...); err != nil {
+		if err := cmd.RunCLI(ctx, cfg, query, transports...); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito/pkg/xlog"
+)
+
+// HTTPOptions configures how ServeHTTP exposes a server
+type HTTPOptions struct {
+	Addr  string // Address to listen on, e.g. "127.0.0.1:8080"
+	Token string // Bearer token clients must send, required
+	// AllowRemote allows listening on addresses other hosts can reach
+	AllowRemote bool
+}
+
+// ServeStdio runs server on stdin and stdout until the client disconnects or
+// ctx is done
+func ServeStdio(ctx context.Context, server *mcp.Server) error {
+	return server.Run(ctx, &mcp.StdioTransport{})
+}
+
+// ServeHTTP serves server over streamable HTTP until ctx is done. Clients
+// must send the token as a bearer token. Unless opts.AllowRemote is set, it
+// only listens on loopback addresses and rejects requests for other hosts or
+// from web pages of other origins, so a DNS rebinding page can't reach it.
+func ServeHTTP(ctx context.Context, server *mcp.Server, opts HTTPOptions) error {
+	if opts.Token == "" {
+		return errors.New("serving over HTTP needs a token, as anyone reaching the address can run commands")
+	}
+	host, _, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", opts.Addr, err)
+	}
+	if !opts.AllowRemote && !isLoopback(host) {
+		return fmt.Errorf("refusing to listen on %q, other hosts could reach it: use a loopback address like 127.0.0.1 or allow remote clients explicitly", opts.Addr)
+	}
+
+	var handler http.Handler = mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+	handler = requireToken(handler, opts.Token)
+	if !opts.AllowRemote {
+		handler = requireLocal(handler)
+	}
+
+	httpServer := &http.Server{Addr: opts.Addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			xlog.Warn("Failed to shut down MCP HTTP server", "error", err)
+		}
+	}()
+
+	xlog.Info("Serving MCP over HTTP", "addr", opts.Addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// requireToken rejects the requests without the bearer token
+func requireToken(next http.Handler, token string) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireLocal rejects the requests whose Host or Origin isn't a loopback
+// address: browsers send the attacker's host name after DNS rebinding
+func requireLocal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(hostname(r.Host)) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !isLoopback(u.Hostname()) {
+				http.Error(w, "forbidden origin", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hostname returns the host of a "host:port" value, which may have no port
+func hostname(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
+
+// isLoopback returns true if host names or is a loopback address
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
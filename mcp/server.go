@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxStderrBytes bounds the error output kept for each server
+const maxStderrBytes = 4096
+
+// Server is a transport to a configured MCP server. Unlike the SDK transports,
+// it can be connected again after the server crashed, and it reports the
+// process it started, if any.
+type Server struct {
+	name         string
+	newTransport func() (mcp.Transport, *exec.Cmd)
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stderr *tailBuffer
+}
+
+// NewServer creates a server whose transport is built by newTransport on every
+// connection. newTransport returns the command it runs, if any.
+func NewServer(name string, newTransport func() (mcp.Transport, *exec.Cmd)) *Server {
+	return &Server{name: name, newTransport: newTransport, stderr: &tailBuffer{}}
+}
+
+// Connect implements mcp.Transport
+func (s *Server) Connect(ctx context.Context) (mcp.Connection, error) {
+	transport, cmd := s.newTransport()
+	if cmd != nil {
+		cmd.Stderr = s.stderr
+	}
+
+	s.mu.Lock()
+	s.cmd = cmd
+	s.mu.Unlock()
+
+	return transport.Connect(ctx)
+}
+
+// Name returns the name of the server in the configuration
+func (s *Server) Name() string {
+	return s.name
+}
+
+// PID returns the process ID of the server started by the last connection, or
+// 0 if the server doesn't run as a local process
+func (s *Server) PID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil || s.cmd.Process == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+// Stderr returns the end of the error output of the server process
+func (s *Server) Stderr() string {
+	return s.stderr.String()
+}
+
+// tailBuffer keeps the last maxStderrBytes bytes written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > maxStderrBytes {
+		b.data = b.data[len(b.data)-maxStderrBytes:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
@@ -110,8 +110,9 @@ func executeCommand(ctx context.Context, req *mcp.CallToolRequest, input execute
 	return nil, output, nil
 }
 
-func startBashMCPServer(ctx context.Context, transport mcp.Transport) error {
-	// Create MCP server for shell command execution
+// NewShellServer creates the MCP server providing the bash tool. The outputs
+// of the scripts are passed through redact, when given, to hide secrets.
+func NewShellServer(redact func(string) string) *mcp.Server {
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "shell",
 		Version: "v1.0.0",
@@ -121,10 +122,23 @@ func startBashMCPServer(ctx context.Context, transport mcp.Transport) error {
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "bash",
 		Description: "Execute a shell script and return the output, exit code, and any errors. The shell command can be configured via SHELL_CMD environment variable (default: 'sh')",
-	}, executeCommand)
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input executeCommandInput) (*mcp.CallToolResult, executeCommandOutput, error) {
+		result, output, err := executeCommand(ctx, req, input)
+		if redact != nil {
+			output.Stdout = redact(output.Stdout)
+			output.Stderr = redact(output.Stderr)
+			output.Error = redact(output.Error)
+		}
+		return result, output, err
+	})
 
+	return server
+}
+
+func startBashMCPServer(ctx context.Context, transport mcp.Transport) error {
 	// Run the server
-	if err := server.Run(ctx, transport); err != nil {
+	// The session redacts the results of every tool it calls
+	if err := NewShellServer(nil).Run(ctx, transport); err != nil {
 		return err
 	}
 
@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestShellServerRedacts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	redact := func(text string) string { return strings.ReplaceAll(text, "hunter2", "[REDACTED]") }
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	go NewShellServer(redact).Run(ctx, serverTransport)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer session.Close()
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name: "bash",
+		// The script builds the secret, so that it only shows in the outputs
+		Arguments: map[string]any{"script": "echo out hunter$((1+1)); echo err hunter$((1+1)) >&2"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+
+	var text strings.Builder
+	for _, content := range result.Content {
+		if c, ok := content.(*mcp.TextContent); ok {
+			text.WriteString(c.Text)
+		}
+	}
+	if strings.Contains(text.String(), "hunter2") {
+		t.Fatalf("secret in the tool output: %s", text.String())
+	}
+	if !strings.Contains(text.String(), "out [REDACTED]") || !strings.Contains(text.String(), "err [REDACTED]") {
+		t.Fatalf("expected the redacted output, got: %s", text.String())
+	}
+}
@@ -3,43 +3,106 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
+	"sort"
 
 	"github.com/mudler/wiz/types"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/cogito/pkg/xlog"
 )
 
 // commandTransport creates a new transport for a command
-func commandTransport(cmd string, args []string, env ...string) mcp.Transport {
+func commandTransport(cmd string, args []string, env ...string) (mcp.Transport, *exec.Cmd) {
 	command := exec.Command(cmd, args...)
 	command.Env = os.Environ()
 	command.Env = append(command.Env, env...)
 
 	transport := &mcp.CommandTransport{Command: command}
-	return transport
+	return transport, command
 }
 
+// StartTransports returns the transports to the built-in and the configured
+// MCP servers. Servers are started when the transports are connected.
 func StartTransports(ctx context.Context, cfg types.Config) ([]mcp.Transport, error) {
-	// Set MCP servers
-	bashMCPServerTransport, bashMCPServerClient := mcp.NewInMemoryTransports()
+	// The built-in bash server runs in process, a new one for every connection
+	bash := NewServer("bash", func() (mcp.Transport, *exec.Cmd) {
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		go func() {
+			if err := startBashMCPServer(ctx, serverTransport); err != nil {
+				xlog.Warn("MCP server error", "server", "bash", "error", err)
+			}
+		}()
+		return clientTransport, nil
+	})
 
-	go func() {
-		if err := startBashMCPServer(ctx, bashMCPServerTransport); err != nil {
-			fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
-		}
-	}()
+	transports := []mcp.Transport{bash}
+
+	names := []string{}
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	transports := []mcp.Transport{bashMCPServerClient}
+	for _, name := range names {
+		c := cfg.MCPServers[name]
+		if c.URL != "" {
+			transport, err := httpTransport(c)
+			if err != nil {
+				return nil, fmt.Errorf("MCP server %s: %w", name, err)
+			}
+			transports = append(transports, NewServer(name, func() (mcp.Transport, *exec.Cmd) {
+				return transport, nil
+			}))
+			continue
+		}
 
-	for _, c := range cfg.MCPServers {
 		envs := []string{}
 		for k, v := range c.Env {
 			envs = append(envs, fmt.Sprintf("%s=%s", k, v))
 		}
-		transports = append(transports, commandTransport(c.Command, c.Args, envs...))
+		transports = append(transports, NewServer(name, func() (mcp.Transport, *exec.Cmd) {
+			return commandTransport(c.Command, c.Args, envs...)
+		}))
 	}
 
 	return transports, nil
 }
+
+// httpTransport creates a transport for a server reached over HTTP
+func httpTransport(c types.MCPServer) (mcp.Transport, error) {
+	if c.Command != "" {
+		return nil, fmt.Errorf("set either command or url, not both")
+	}
+
+	client := http.DefaultClient
+	if len(c.Headers) > 0 {
+		client = &http.Client{Transport: &headerRoundTripper{headers: c.Headers, base: http.DefaultTransport}}
+	}
+
+	switch c.Transport {
+	case "", "streamable-http":
+		return &mcp.StreamableClientTransport{Endpoint: c.URL, HTTPClient: client}, nil
+	case "sse":
+		return &mcp.SSEClientTransport{Endpoint: c.URL, HTTPClient: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q, expected streamable-http or sse", c.Transport)
+	}
+}
+
+// headerRoundTripper adds headers, e.g. for authentication, to every request
+type headerRoundTripper struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	return h.base.RoundTrip(req)
+}
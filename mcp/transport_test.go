@@ -0,0 +1,122 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/wiz/types"
+)
+
+// headerRecorder records a header of the requests reaching a handler
+type headerRecorder struct {
+	mu     sync.Mutex
+	name   string
+	values []string
+}
+
+func (h *headerRecorder) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		h.values = append(h.values, r.Header.Get(h.name))
+		h.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *headerRecorder) seen() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.values...)
+}
+
+func TestHTTPTransports(t *testing.T) {
+	getServer := func(*http.Request) *mcp.Server { return NewShellServer(nil) }
+	handlers := map[string]http.Handler{
+		"":                mcp.NewStreamableHTTPHandler(getServer, nil),
+		"streamable-http": mcp.NewStreamableHTTPHandler(getServer, nil),
+		"sse":             mcp.NewSSEHandler(getServer, nil),
+	}
+
+	for transport, handler := range handlers {
+		t.Run("transport="+transport, func(t *testing.T) {
+			recorder := &headerRecorder{name: "Authorization"}
+			ts := httptest.NewServer(recorder.wrap(handler))
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			clientTransport, err := httpTransport(types.MCPServer{
+				URL:       ts.URL,
+				Transport: transport,
+				Headers:   map[string]string{"Authorization": "Bearer secret"},
+			})
+			if err != nil {
+				t.Fatalf("httpTransport: %v", err)
+			}
+
+			client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+			session, err := client.Connect(ctx, clientTransport, nil)
+			if err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer session.Close()
+
+			tools, err := session.ListTools(ctx, nil)
+			if err != nil {
+				t.Fatalf("ListTools: %v", err)
+			}
+			if len(tools.Tools) != 1 || tools.Tools[0].Name != "bash" {
+				t.Fatalf("expected the bash tool, got %+v", tools.Tools)
+			}
+
+			seen := recorder.seen()
+			if len(seen) == 0 {
+				t.Fatal("no request reached the server")
+			}
+			for i, value := range seen {
+				if value != "Bearer secret" {
+					t.Errorf("request %d: Authorization = %q, want the configured header", i, value)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPTransportErrors(t *testing.T) {
+	tests := map[string]struct {
+		server types.MCPServer
+		err    string
+	}{
+		"command and url": {
+			server: types.MCPServer{URL: "http://127.0.0.1:1/mcp", Command: "mcp-server"},
+			err:    "not both",
+		},
+		"unknown transport": {
+			server: types.MCPServer{URL: "http://127.0.0.1:1/mcp", Transport: "websocket"},
+			err:    "unknown transport",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := httpTransport(test.server)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error containing %q, got %v", test.err, err)
+			}
+
+			// StartTransports reports it with the server name
+			cfg := types.Config{MCPServers: map[string]types.MCPServer{"remote": test.server}}
+			_, err = StartTransports(context.Background(), cfg)
+			if err == nil || !strings.Contains(err.Error(), "remote") {
+				t.Fatalf("expected StartTransports to fail for server remote, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"strings"
+)
+
+// OutputMode tells the shell widget what to do with the command wiz returns
+type OutputMode int
+
+const (
+	// ReplaceCommandLine replaces the command line being edited with the command
+	ReplaceCommandLine OutputMode = iota
+	// InsertCommand inserts the command at the cursor position
+	InsertCommand
+)
+
+// shellLanguages are the code block languages that hold a shell command
+var shellLanguages = map[string]bool{
+	"":        true,
+	"sh":      true,
+	"bash":    true,
+	"zsh":     true,
+	"fish":    true,
+	"shell":   true,
+	"console": true,
+}
+
+// extractCommand returns the first shell command found in a fenced code block
+// of the response, or an empty string if there is none
+func extractCommand(response string) string {
+	lines := strings.Split(response, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lang, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "```")
+		if !ok {
+			continue
+		}
+
+		var block []string
+		for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+			block = append(block, lines[i])
+		}
+
+		if !shellLanguages[strings.ToLower(strings.TrimSpace(lang))] {
+			continue
+		}
+
+		command := strings.TrimSpace(strings.Join(block, "\n"))
+		// Drop the prompt sign in console-style examples
+		command = strings.TrimPrefix(command, "$ ")
+		if command != "" {
+			return command
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/cogito/pkg/xlog"
+)
+
+// maxHistoryEntries bounds the number of questions kept in the history file
+const maxHistoryEntries = 1000
+
+// inputHistory holds the previously asked questions, persisted across runs
+type inputHistory struct {
+	path    string
+	entries []string // Oldest first
+	pos     int      // Position while browsing, len(entries) when not browsing
+	draft   string   // Input being typed before browsing started
+}
+
+// loadInputHistory reads the history file. Entries are stored one per line as
+// JSON strings, so that multi-line questions fit on a line.
+func loadInputHistory(path string) *inputHistory {
+	h := &inputHistory{path: path}
+
+	if f, err := os.Open(path); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var entry string
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry != "" {
+				h.entries = append(h.entries, entry)
+			}
+		}
+	}
+
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[len(h.entries)-maxHistoryEntries:]
+	}
+	h.pos = len(h.entries)
+	return h
+}
+
+// add appends a question to the history and to the history file
+func (h *inputHistory) add(entry string) {
+	defer h.reset()
+
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		xlog.Warn("Failed to create history directory", "error", err)
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		xlog.Warn("Failed to open history file", "error", err)
+		return
+	}
+	defer f.Close()
+
+	data, _ := json.Marshal(entry)
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		xlog.Warn("Failed to write history file", "error", err)
+	}
+}
+
+// reset stops browsing the history
+func (h *inputHistory) reset() {
+	h.pos = len(h.entries)
+	h.draft = ""
+}
+
+// previous returns the entry before the current one. current is the input
+// being typed, restored when browsing past the most recent entry.
+func (h *inputHistory) previous(current string) (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	if h.pos == len(h.entries) {
+		h.draft = current
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// next returns the entry after the current one, or the draft when done browsing
+func (h *inputHistory) next() (string, bool) {
+	if h.pos >= len(h.entries) {
+		return "", false
+	}
+	h.pos++
+	if h.pos == len(h.entries) {
+		return h.draft, true
+	}
+	return h.entries[h.pos], true
+}
+
+// search returns the entries fuzzy matching query, most recent first
+func (h *inputHistory) search(query string) []string {
+	matches := []string{}
+	seen := map[string]bool{}
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entry := h.entries[i]
+		if !seen[entry] && fuzzyMatch(entry, query) {
+			matches = append(matches, entry)
+			seen[entry] = true
+		}
+	}
+	return matches
+}
+
+// fuzzyMatch returns true if all the characters of query appear in text, in order
+func fuzzyMatch(text, query string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(text, r)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(string(r)):]
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/mudler/wiz/types"
+)
+
+// keyMap holds the TUI key bindings
+type keyMap struct {
+	Send          key.Binding
+	Quit          key.Binding
+	UseCommand    key.Binding
+	InsertCommand key.Binding
+	Complete      key.Binding
+	Newline       key.Binding
+
+	HistoryPrevious key.Binding
+	HistoryNext     key.Binding
+	Search          key.Binding
+	Navigate        key.Binding
+}
+
+// newKeyMap builds the TUI key bindings from the configuration
+func newKeyMap(kb types.KeyBindings) keyMap {
+	return keyMap{
+		Send:          newBinding(kb.Send, "send"),
+		Quit:          newBinding(kb.Quit, "exit"),
+		UseCommand:    newBinding(kb.UseCommand, "use command"),
+		InsertCommand: newBinding(kb.InsertCommand, "insert command"),
+		Complete:      newBinding(kb.Complete, "complete"),
+		Newline:       newBinding(kb.Newline, "newline"),
+
+		HistoryPrevious: newBinding(kb.HistoryPrevious, "previous question"),
+		HistoryNext:     newBinding(kb.HistoryNext, "next question"),
+		Search:          newBinding(kb.Search, "search history"),
+		Navigate:        newBinding(kb.Navigate, "scroll"),
+	}
+}
+
+// newBinding creates a key binding whose help shows the first of its keys
+func newBinding(keys []string, help string) key.Binding {
+	if len(keys) == 0 {
+		return key.NewBinding(key.WithDisabled())
+	}
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(displayKey(keys[0]), help))
+}
+
+// displayKey formats a key for the help line, e.g. "ctrl+y" as "Ctrl+Y"
+func displayKey(k string) string {
+	parts := strings.Split(k, "+")
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = strings.ToUpper(part)
+		} else {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// helpEntry renders a binding for the help line
+func helpEntry(b key.Binding) string {
+	return b.Help().Key + ": " + b.Help().Desc
+}
@@ -2,11 +2,16 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/mudler/wiz/commands"
+	"github.com/mudler/wiz/config"
 	"github.com/mudler/wiz/types"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -18,8 +23,10 @@ import (
 
 // ChatMessage represents a message in the chat history
 type ChatMessage struct {
-	Role    string
-	Content string
+	Role      string
+	Content   string
+	Collapsed bool             // Only the first line is shown
+	Tool      *chat.ToolResult // Set for tool results
 }
 
 // Model represents the TUI state
@@ -30,13 +37,17 @@ type Model struct {
 	spinner  spinner.Model
 
 	// Chat state
-	messages     []ChatMessage
-	session      *chat.Session
-	ctx          context.Context
-	cancel       context.CancelFunc
-	transports   []mcp.Transport
-	cfg          types.Config
-	sessionReady bool
+	messages      []ChatMessage
+	session       *chat.Session
+	ctx           context.Context
+	cancel        context.CancelFunc
+	cancelRequest context.CancelFunc // Cancels the request in flight, if any
+	cancelling    bool
+	transports    []mcp.Transport
+	cfg           types.Config
+	sessionReady  bool
+	commands      *commands.Registry
+	keys          keyMap
 
 	// UI state
 	width     int
@@ -47,8 +58,28 @@ type Model struct {
 	reasoning string
 	err       error
 	output    string // Command to output to shell on exit
+	mode      OutputMode
+	command   string // Command suggested in the last response
 	quitting  bool
 
+	// Shell command line state
+	commandLineSent bool   // Whether the command line was already given as context
+	query           string // Message to send as soon as the session is ready
+
+	// Input history state
+	history       *inputHistory
+	searching     bool
+	searchQuery   string
+	searchMatches []string
+	searchIndex   int
+
+	// Transcript navigation state
+	navigating     bool
+	selected       int   // Index of the selected message
+	messageOffsets []int // First viewport line of each message
+	finding        bool
+	findQuery      string
+
 	// Tool approval state
 	pendingTool      *chat.ToolCallRequest
 	awaitingApproval bool
@@ -61,6 +92,8 @@ type Model struct {
 	reasoningChan    chan string
 	toolRequestChan  chan chat.ToolCallRequest
 	toolResponseChan chan chat.ToolCallResponse
+	toolResultChan   chan chat.ToolResult
+	warningChan      chan string
 }
 
 // responseMsg is sent when the AI responds
@@ -78,12 +111,25 @@ type reasoningMsg string
 // toolCallMsg is sent when a tool call needs approval
 type toolCallMsg chat.ToolCallRequest
 
+// toolResultMsg is sent when a tool has been run
+type toolResultMsg chat.ToolResult
+
+// warningMsg is sent for problems the session goes on with
+type warningMsg string
+
 // sessionReadyMsg is sent when the session is initialized
 type sessionReadyMsg struct {
 	session *chat.Session
 	err     error
 }
 
+// promptsMsg is sent when the MCP prompts are listed, to handle the input
+// that needed them
+type promptsMsg struct {
+	prompts []chat.PromptInfo
+	input   string
+}
+
 // NewModel creates a new TUI model
 func NewModel(ctx context.Context, cfg types.Config, height int, transports ...mcp.Transport) Model {
 	ctx, cancel := context.WithCancel(ctx)
@@ -96,10 +142,19 @@ func NewModel(ctx context.Context, cfg types.Config, height int, transports ...m
 	ta.SetWidth(80)
 	ta.SetHeight(3)
 	ta.ShowLineNumbers = false
-	ta.KeyMap.InsertNewline.SetEnabled(false) // Enter sends message
+	// Enter sends the message, newlines are inserted with the configured keys
+	keys := newKeyMap(cfg.KeyBindings)
+	ta.KeyMap.InsertNewline = keys.Newline
 
 	vp := viewport.New(80, 10)
-	vp.SetContent("✨ Welcome! The wizard awaits your command.\n\nType your question and press Enter. Press Esc to exit.")
+	// Keys are handled by the model, so that typing doesn't scroll the viewport
+	vp.KeyMap = viewport.KeyMap{}
+	welcome := "✨ Welcome! The wizard awaits your command.\n\nType your question and press Enter. Type /help for commands, press Esc to exit."
+	if cfg.CommandLine != "" {
+		welcome += "\n\n" + dimmedStyle.Render("Command line: ") + cfg.CommandLine +
+			"\n" + dimmedStyle.Render("Press Enter on an empty input to ask about it.")
+	}
+	vp.SetContent(welcome)
 
 	s := spinner.New()
 	s.Spinner = spinner.Points
@@ -121,12 +176,32 @@ func NewModel(ctx context.Context, cfg types.Config, height int, transports ...m
 		maxHeight:        maxH,
 		transports:       transports,
 		cfg:              cfg,
+		commands:         commands.Load(config.CommandDirs()...),
+		keys:             keys,
+		history:          loadInputHistory(historyFile()),
 		height:           height,
 		statusChan:       make(chan string, 10),
 		reasoningChan:    make(chan string, 10),
 		toolRequestChan:  make(chan chat.ToolCallRequest),
 		toolResponseChan: make(chan chat.ToolCallResponse),
+		toolResultChan:   make(chan chat.ToolResult, 10),
+		warningChan:      make(chan string, 10),
+	}
+}
+
+// historyFile returns the path of the input history file
+func historyFile() string {
+	dir := config.StateDir()
+	if dir == "" {
+		return ""
 	}
+	return filepath.Join(dir, "history")
+}
+
+// WithQuery returns a copy of the model that sends query as soon as the session is ready
+func (m Model) WithQuery(query string) Model {
+	m.query = query
+	return m
 }
 
 // Init initializes the model
@@ -135,6 +210,8 @@ func (m Model) Init() tea.Cmd {
 		textarea.Blink,
 		m.spinner.Tick,
 		m.initSession(),
+		// Servers failing to start warn while the session is created
+		m.listenWarning(),
 	)
 }
 
@@ -159,10 +236,28 @@ func (m Model) initSession() tea.Cmd {
 				m.toolRequestChan <- req
 				return <-m.toolResponseChan
 			},
+			// Tool results and warnings are part of the conversation: unlike
+			// the status, they are never dropped, the session waits for the
+			// model to take them
+			OnToolResult: func(result chat.ToolResult) {
+				select {
+				case m.toolResultChan <- result:
+				case <-m.ctx.Done():
+				}
+			},
+			OnWarning: func(message string) {
+				select {
+				case m.warningChan <- message:
+				case <-m.ctx.Done():
+				}
+			},
 		}
 
 		session, err := chat.NewSession(m.ctx, m.cfg, callbacks, m.transports...)
-		return sessionReadyMsg{session: session, err: err}
+		if err != nil {
+			return sessionReadyMsg{err: err}
+		}
+		return sessionReadyMsg{session: session}
 	}
 }
 
@@ -173,8 +268,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		if m.searching {
+			return m.handleSearchKey(msg)
+		}
+		if m.navigating {
+			return m.handleNavigationKey(msg)
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Quit) && m.cancelRequest != nil && !m.cancelling:
+			// Interrupt the request in flight, a second press exits
+			return m.interrupt()
+
+		case key.Matches(msg, m.keys.Quit):
 			m.quitting = true
 			if m.session != nil {
 				m.session.Close()
@@ -182,12 +288,70 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cancel()
 			return m, tea.Quit
 
-		case tea.KeyEnter:
+		case key.Matches(msg, m.keys.UseCommand, m.keys.InsertCommand):
+			if m.command != "" && !m.loading && !m.awaitingApproval {
+				m.output = m.command
+				m.mode = ReplaceCommandLine
+				if key.Matches(msg, m.keys.InsertCommand) {
+					m.mode = InsertCommand
+				}
+				m.quitting = true
+				if m.session != nil {
+					m.session.Close()
+				}
+				m.cancel()
+				return m, tea.Quit
+			}
+
+		case key.Matches(msg, m.keys.Navigate):
+			m.startNavigation()
+			// A navigation key, like PgUp, also does what it does there
+			if !key.Matches(msg, navigationKeys.Leave) {
+				return m.handleNavigationKey(msg)
+			}
+			return m, nil
+
+		case key.Matches(msg, m.keys.Search):
+			if !m.loading && m.sessionReady && !m.awaitingApproval {
+				m.searching = true
+				m.searchQuery = ""
+				m.searchIndex = 0
+				m.searchMatches = m.history.search("")
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.HistoryPrevious):
+			if !m.loading && !m.awaitingApproval && m.textarea.Line() == 0 {
+				if entry, ok := m.history.previous(m.textarea.Value()); ok {
+					m.textarea.SetValue(entry)
+				}
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.HistoryNext):
+			if !m.loading && !m.awaitingApproval && m.textarea.Line() == m.textarea.LineCount()-1 {
+				if entry, ok := m.history.next(); ok {
+					m.textarea.SetValue(entry)
+				}
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.Complete):
+			if !m.awaitingApproval && commands.HasPrefix(m.textarea.Value()) {
+				m.completeCommand()
+				return m, nil
+			}
+
+		case key.Matches(msg, m.keys.Send):
 			if m.loading || !m.sessionReady {
 				return m, nil
 			}
 
 			input := strings.TrimSpace(m.textarea.Value())
+			if input == "" && !m.awaitingApproval && !m.commandLineSent {
+				// Ask about the command line being edited in the shell
+				input = strings.TrimSpace(m.cfg.CommandLine)
+			}
 			if input == "" {
 				return m, nil
 			}
@@ -197,17 +361,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m.handleToolApproval(input)
 			}
 
-			// Add user message
-			m.messages = append(m.messages, ChatMessage{
-				Role:    "user",
-				Content: input,
-			})
-			m.textarea.Reset()
-			m.loading = true
-			m.status = "Thinking..."
-			m.updateViewport()
+			m.history.add(input)
 
-			return m, m.sendMessage(input)
+			if m.commands.NeedsPrompts(input) {
+				return m.loadPrompts(input)
+			}
+			if m.commands.IsCommand(input) {
+				return m.runCommand(input)
+			}
+			return m.ask(input)
 		}
 
 	case tea.WindowSizeMsg:
@@ -222,25 +384,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.session = msg.session
 		m.sessionReady = true
+		m.commands.AddPromptsLater(m.session.ListPrompts)
 		// Start listening for callbacks
-		cmds = append(cmds, m.listenStatus(), m.listenReasoning(), m.listenToolRequest())
+		cmds = append(cmds, m.listenStatus(), m.listenReasoning(), m.listenToolRequest(), m.listenToolResult())
+
+		if m.query != "" {
+			m.commandLineSent = true
+			m.messages = append(m.messages, ChatMessage{Role: "user", Content: m.query})
+			m.loading = true
+			m.status = "Thinking..."
+			m.updateViewport()
+			cmds = append(cmds, m.sendMessage(m.query))
+		}
+
+	case promptsMsg:
+		m.loading = false
+		m.status = ""
+		m.commands.AddPrompts(msg.prompts)
+		if m.commands.IsCommand(msg.input) {
+			return m.runCommand(msg.input)
+		}
+		return m.ask(msg.input)
 
 	case responseMsg:
 		m.loading = false
 		m.status = ""
-		m.reasoning = ""
-		if msg.err != nil {
+		m.cancelRequest = nil
+		m.cancelling = false
+		if errors.Is(msg.err, context.Canceled) {
+			m.messages = append(m.messages, ChatMessage{
+				Role:    "system",
+				Content: "Request cancelled",
+			})
+		} else if msg.err != nil {
 			m.err = msg.err
 			m.messages = append(m.messages, ChatMessage{
 				Role:    "error",
 				Content: msg.err.Error(),
 			})
 		} else {
+			if m.reasoning != "" {
+				// Keep the reasoning in the transcript, collapsed
+				m.messages = append(m.messages, ChatMessage{
+					Role:      "reasoning",
+					Content:   m.reasoning,
+					Collapsed: true,
+				})
+			}
 			m.messages = append(m.messages, ChatMessage{
 				Role:    "assistant",
 				Content: msg.content,
 			})
+			m.command = extractCommand(msg.content)
 		}
+		m.reasoning = ""
 		m.updateViewport()
 
 	case statusMsg:
@@ -263,6 +460,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Continue listening for more tool requests
 		cmds = append(cmds, m.listenToolRequest())
 
+	case toolResultMsg:
+		m.messages = append(m.messages, toolMessage(chat.ToolResult(msg)))
+		m.updateViewport()
+		// Continue listening for more tool results
+		cmds = append(cmds, m.listenToolResult())
+
+	case warningMsg:
+		m.messages = append(m.messages, ChatMessage{Role: "warning", Content: string(msg)})
+		m.updateViewport()
+		cmds = append(cmds, m.listenWarning())
+
 	case spinner.TickMsg:
 		m.spinner, cmd = m.spinner.Update(msg)
 		cmds = append(cmds, cmd)
@@ -286,14 +494,228 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-// sendMessage sends a message to the AI
-func (m Model) sendMessage(text string) tea.Cmd {
+// handleSearchKey handles keys while searching the input history
+func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Quit):
+		m.searching = false
+		return m, nil
+
+	case key.Matches(msg, m.keys.Send):
+		m.searching = false
+		if len(m.searchMatches) > 0 {
+			m.textarea.SetValue(m.searchMatches[m.searchIndex])
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Search):
+		// Cycle through older matches
+		if len(m.searchMatches) > 0 {
+			m.searchIndex = (m.searchIndex + 1) % len(m.searchMatches)
+		}
+		return m, nil
+	}
+
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if runes := []rune(m.searchQuery); len(runes) > 0 {
+			m.searchQuery = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.searchQuery += string(msg.Runes)
+	default:
+		return m, nil
+	}
+
+	m.searchMatches = m.history.search(m.searchQuery)
+	m.searchIndex = 0
+	return m, nil
+}
+
+// searchView renders the history search line
+func (m Model) searchView() string {
+	match := dimmedStyle.Render("no match")
+	if len(m.searchMatches) > 0 {
+		// Show multi-line entries on a single line
+		match = strings.ReplaceAll(m.searchMatches[m.searchIndex], "\n", " ⏎ ")
+	}
+	return promptHintStyle.Render("(search) ") + m.searchQuery + dimmedStyle.Render(": ") + match
+}
+
+// ask sends a question typed in the input area
+func (m Model) ask(input string) (tea.Model, tea.Cmd) {
+	m.messages = append(m.messages, ChatMessage{
+		Role:    "user",
+		Content: input,
+	})
+	m.textarea.Reset()
+	m.loading = true
+	m.status = "Thinking..."
+	m.updateViewport()
+
+	return m, m.sendMessage(m.withCommandLine(input))
+}
+
+// loadPrompts lists the MCP prompts, needed to tell whether input runs one
+func (m Model) loadPrompts(input string) (tea.Model, tea.Cmd) {
+	m.textarea.Reset()
+	// Listing them connects to the servers, don't block the UI meanwhile
+	m.loading = true
+	m.status = "Listing MCP prompts..."
+	session := m.session
+	return m, func() tea.Msg {
+		// Servers failing to list them are logged and skipped
+		prompts, _ := session.ListPrompts()
+		return promptsMsg{prompts: prompts, input: input}
+	}
+}
+
+// runCommand executes a slash command typed in the input area
+func (m Model) runCommand(input string) (tea.Model, tea.Cmd) {
+	m.textarea.Reset()
+
+	result, err := m.commands.Execute(commands.Env{Session: m.session, Config: m.cfg}, input)
+	if err != nil {
+		m.messages = append(m.messages, ChatMessage{Role: "error", Content: err.Error()})
+		m.updateViewport()
+		return m, nil
+	}
+
+	if result.Exit {
+		m.quitting = true
+		m.session.Close()
+		m.cancel()
+		return m, tea.Quit
+	}
+	if result.Clear {
+		m.messages = []ChatMessage{}
+	}
+	if result.Rewind {
+		m.rewindMessages()
+		m.command = ""
+	}
+	if result.Reload {
+		m.loadMessages()
+		m.command = ""
+	}
+	if result.Input != "" {
+		m.textarea.SetValue(result.Input)
+	}
+	if result.Output != "" {
+		m.messages = append(m.messages, ChatMessage{Role: "system", Content: result.Output})
+	}
+	if result.Send == "" {
+		m.updateViewport()
+		return m, nil
+	}
+
+	m.messages = append(m.messages, ChatMessage{Role: "user", Content: result.Send})
+	m.loading = true
+	m.status = "Thinking..."
+	m.updateViewport()
+
+	cmd := m.sendMessage(m.withCommandLine(result.Send))
+	return m, cmd
+}
+
+// rewindMessages removes the last question and everything after it from the transcript
+func (m *Model) rewindMessages() {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "user" {
+			m.messages = m.messages[:i]
+			return
+		}
+	}
+}
+
+// loadMessages shows the current branch of the conversation
+func (m *Model) loadMessages() {
+	m.messages = []ChatMessage{}
+	for _, msg := range m.session.GetMessages() {
+		switch msg.Role {
+		case "tool":
+			m.messages = append(m.messages, toolMessage(*msg.Tool))
+		case "reasoning":
+			m.messages = append(m.messages, ChatMessage{Role: msg.Role, Content: msg.Content, Collapsed: true})
+		default:
+			m.messages = append(m.messages, ChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+}
+
+// toolMessage returns the transcript message showing a tool result
+func toolMessage(result chat.ToolResult) ChatMessage {
+	content := result.Output
+	if result.IsCommand() {
+		content = strings.TrimSpace(result.Stdout + "\n" + result.Stderr)
+	}
+	return ChatMessage{Role: "tool", Content: content, Tool: &result}
+}
+
+// withCommandLine adds the shell command line to the first message, unless
+// the system prompt already includes it
+func (m *Model) withCommandLine(input string) string {
+	if m.commandLineSent || m.cfg.CommandLine == "" {
+		return input
+	}
+	m.commandLineSent = true
+
+	if m.cfg.Context.CommandLine || strings.TrimSpace(m.cfg.CommandLine) == input {
+		return input
+	}
+	return fmt.Sprintf("The command line I am editing in my shell is: %s\n\n%s", m.cfg.CommandLine, input)
+}
+
+// completeCommand completes the slash command typed in the input area
+func (m *Model) completeCommand() {
+	completions := m.commands.Complete(m.textarea.Value())
+	switch len(completions) {
+	case 0:
+		return
+	case 1:
+		m.textarea.SetValue(completions[0] + " ")
+	default:
+		m.textarea.SetValue(commands.CommonPrefix(completions))
+		m.messages = append(m.messages, ChatMessage{Role: "system", Content: strings.Join(completions, "  ")})
+		m.updateViewport()
+	}
+}
+
+// sendMessage sends a message to the AI. The request can be interrupted with interrupt.
+func (m *Model) sendMessage(text string) tea.Cmd {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelRequest = cancel
+
+	session := m.session
 	return func() tea.Msg {
-		response, err := m.session.SendMessage(text)
+		defer cancel()
+		response, err := session.SendMessageContext(ctx, text)
 		return responseMsg{content: response, err: err}
 	}
 }
 
+// interrupt cancels the request in flight, keeping the conversation. Pressing
+// the quit key again before the request is done exits.
+func (m Model) interrupt() (tea.Model, tea.Cmd) {
+	m.cancelRequest()
+	m.cancelling = true
+	m.status = "Cancelling..."
+
+	var cmd tea.Cmd
+	if m.awaitingApproval {
+		// Unblock the tool approval callback
+		m.awaitingApproval = false
+		m.pendingTool = nil
+		m.loading = true
+		cmd = func() tea.Msg {
+			m.toolResponseChan <- chat.ToolCallResponse{Approved: false}
+			return nil
+		}
+	}
+	m.updateViewport()
+	return m, cmd
+}
+
 // listenStatus listens for status updates from the session
 func (m Model) listenStatus() tea.Cmd {
 	return func() tea.Msg {
@@ -330,6 +752,30 @@ func (m Model) listenToolRequest() tea.Cmd {
 	}
 }
 
+// listenToolResult listens for tool results from the session
+func (m Model) listenToolResult() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case result := <-m.toolResultChan:
+			return toolResultMsg(result)
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// listenWarning listens for warnings from the session
+func (m Model) listenWarning() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case message := <-m.warningChan:
+			return warningMsg(message)
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
 // handleToolApproval handles tool approval input
 func (m Model) handleToolApproval(input string) (tea.Model, tea.Cmd) {
 	input = strings.ToLower(strings.TrimSpace(input))
@@ -429,21 +875,18 @@ func (m *Model) getThinkingStatus() string {
 func (m *Model) updateViewport() {
 	var sb strings.Builder
 
-	for _, msg := range m.messages {
-		switch msg.Role {
-		case "user":
-			sb.WriteString(userStyle.Render("👤 You: "))
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
-		case "assistant":
-			sb.WriteString(assistantStyle.Render("🧙 Wiz: "))
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
-		case "error":
-			sb.WriteString(errorStyle.Render("✗ Error: "))
-			sb.WriteString(msg.Content)
-			sb.WriteString("\n\n")
+	m.messageOffsets = m.messageOffsets[:0]
+	offset := 0
+	for i, msg := range m.messages {
+		m.messageOffsets = append(m.messageOffsets, offset)
+		block := m.renderMessage(i, msg)
+		if block == "" {
+			continue
 		}
+		sb.WriteString(block)
+		sb.WriteString("\n\n")
+		// Messages are separated by an empty line
+		offset += lipgloss.Height(block) + 1
 	}
 
 	if m.loading {
@@ -469,6 +912,9 @@ func (m *Model) updateViewport() {
 		// Build tool request box content
 		var toolContent strings.Builder
 		toolContent.WriteString(toolNameStyle.Render("🔧 " + m.pendingTool.Name))
+		if m.pendingTool.Server != "" {
+			toolContent.WriteString(dimmedStyle.Render(" from " + m.pendingTool.Server))
+		}
 		toolContent.WriteString("\n\n")
 		toolContent.WriteString(dimmedStyle.Render("Arguments: "))
 		toolContent.WriteString(m.pendingTool.Arguments)
@@ -485,7 +931,44 @@ func (m *Model) updateViewport() {
 	}
 
 	m.viewport.SetContent(sb.String())
-	m.viewport.GotoBottom()
+	// Follow the conversation, unless the user is reading back
+	if !m.navigating {
+		m.viewport.GotoBottom()
+	}
+}
+
+// renderMessage renders the i-th chat message for the viewport
+func (m *Model) renderMessage(i int, msg ChatMessage) string {
+	var sb strings.Builder
+	if m.navigating && i == m.selected {
+		sb.WriteString(selectedStyle.Render("▶ "))
+	}
+	if msg.Collapsed {
+		msg.Content = collapse(msg.Content)
+	}
+
+	switch msg.Role {
+	case "user":
+		sb.WriteString(userStyle.Render("👤 You: "))
+		sb.WriteString(msg.Content)
+	case "assistant":
+		sb.WriteString(assistantStyle.Render("🧙 Wiz: "))
+		sb.WriteString(msg.Content)
+	case "error":
+		sb.WriteString(errorStyle.Render("✗ Error: "))
+		sb.WriteString(msg.Content)
+	case "system":
+		sb.WriteString(dimmedStyle.Render(msg.Content))
+	case "warning":
+		sb.WriteString(warningStyle.Render("⚠ " + msg.Content))
+	case "reasoning":
+		sb.WriteString(reasoningStyle.Render("💭 " + msg.Content))
+	case "tool":
+		sb.WriteString(renderToolResult(*msg.Tool, msg.Collapsed))
+	default:
+		return ""
+	}
+	return sb.String()
 }
 
 // View renders the TUI
@@ -526,7 +1009,21 @@ func (m Model) View() string {
 
 	// Help text
 	sb.WriteString("\n")
-	sb.WriteString(helpStyle.Render("Enter: send • Esc: exit"))
+	if m.searching {
+		sb.WriteString(m.searchView())
+	} else if m.navigating {
+		sb.WriteString(m.navigationHelp())
+	} else {
+		help := helpEntry(m.keys.Send) + " • " + helpEntry(m.keys.Newline) + " • " + helpEntry(m.keys.Search) +
+			" • " + helpEntry(m.keys.Navigate) + " • " + helpEntry(m.keys.Complete) + " • /help: commands • " + helpEntry(m.keys.Quit)
+		if m.command != "" {
+			if m.cfg.CommandLine != "" {
+				help = helpEntry(m.keys.InsertCommand) + " • " + help
+			}
+			help = helpEntry(m.keys.UseCommand) + " • " + help
+		}
+		sb.WriteString(helpStyle.Render(help))
+	}
 
 	if m.err != nil {
 		sb.WriteString("\n")
@@ -536,7 +1033,8 @@ func (m Model) View() string {
 	return sb.String()
 }
 
-// Output returns any command that should be output to the shell
-func (m Model) Output() string {
-	return m.output
+// Output returns any command that should be output to the shell, and
+// whether it replaces the command line or is inserted in it
+func (m Model) Output() (string, OutputMode) {
+	return m.output, m.mode
 }
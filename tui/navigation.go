@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// navigationKeys are the keys available while navigating the transcript
+var navigationKeys = struct {
+	Leave, PageUp, PageDown, Up, Down, Top, Bottom, PreviousMessage, NextMessage, Toggle, Find, FindNext, FindPrevious key.Binding
+}{
+	Leave:           key.NewBinding(key.WithKeys("esc", "q", "i"), key.WithHelp("Esc", "back")),
+	PageUp:          key.NewBinding(key.WithKeys("pgup", "b"), key.WithHelp("PgUp", "page up")),
+	PageDown:        key.NewBinding(key.WithKeys("pgdown", "f", " "), key.WithHelp("PgDn", "page down")),
+	Up:              key.NewBinding(key.WithKeys("up", "k")),
+	Down:            key.NewBinding(key.WithKeys("down", "j")),
+	Top:             key.NewBinding(key.WithKeys("home", "g")),
+	Bottom:          key.NewBinding(key.WithKeys("end", "G")),
+	PreviousMessage: key.NewBinding(key.WithKeys("[", "shift+tab"), key.WithHelp("[", "previous message")),
+	NextMessage:     key.NewBinding(key.WithKeys("]", "tab"), key.WithHelp("]", "next message")),
+	Toggle:          key.NewBinding(key.WithKeys("enter"), key.WithHelp("Enter", "collapse/expand")),
+	Find:            key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+	FindNext:        key.NewBinding(key.WithKeys("n"), key.WithHelp("n/N", "next/previous match")),
+	FindPrevious:    key.NewBinding(key.WithKeys("N")),
+}
+
+// startNavigation switches to transcript navigation, selecting the last message
+func (m *Model) startNavigation() {
+	m.navigating = true
+	m.selected = len(m.messages) - 1
+	m.updateViewport()
+}
+
+// stopNavigation goes back to the input area, following the end of the transcript
+func (m *Model) stopNavigation() {
+	m.navigating = false
+	m.finding = false
+	m.updateViewport()
+}
+
+// handleNavigationKey handles keys while navigating the transcript
+func (m Model) handleNavigationKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.finding {
+		return m.handleFindKey(msg)
+	}
+
+	k := navigationKeys
+	switch {
+	case key.Matches(msg, k.Leave):
+		m.stopNavigation()
+	case key.Matches(msg, k.PageUp):
+		m.viewport.PageUp()
+	case key.Matches(msg, k.PageDown):
+		m.viewport.PageDown()
+	case key.Matches(msg, k.Up):
+		m.viewport.ScrollUp(1)
+	case key.Matches(msg, k.Down):
+		m.viewport.ScrollDown(1)
+	case key.Matches(msg, k.Top):
+		m.viewport.GotoTop()
+	case key.Matches(msg, k.Bottom):
+		m.viewport.GotoBottom()
+	case key.Matches(msg, k.PreviousMessage):
+		m.selectMessage(m.selected - 1)
+	case key.Matches(msg, k.NextMessage):
+		m.selectMessage(m.selected + 1)
+	case key.Matches(msg, k.Toggle):
+		if m.selected >= 0 && m.selected < len(m.messages) && isCollapsible(m.messages[m.selected]) {
+			m.messages[m.selected].Collapsed = !m.messages[m.selected].Collapsed
+			m.updateViewport()
+		}
+	case key.Matches(msg, k.Find):
+		m.finding = true
+		m.findQuery = ""
+	case key.Matches(msg, k.FindNext):
+		m.findMessage(m.selected+1, 1)
+	case key.Matches(msg, k.FindPrevious):
+		m.findMessage(m.selected-1, -1)
+	}
+
+	return m, nil
+}
+
+// handleFindKey handles keys while typing a transcript search
+func (m Model) handleFindKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.finding = false
+	case tea.KeyEnter:
+		m.finding = false
+		m.findMessage(m.selected, -1)
+	case tea.KeyBackspace:
+		if runes := []rune(m.findQuery); len(runes) > 0 {
+			m.findQuery = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.findQuery += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// selectMessage selects a message and scrolls the viewport to it
+func (m *Model) selectMessage(i int) {
+	if i < 0 || i >= len(m.messages) {
+		return
+	}
+	m.selected = i
+	m.updateViewport()
+	if i < len(m.messageOffsets) {
+		m.viewport.SetYOffset(m.messageOffsets[i])
+	}
+}
+
+// findMessage selects the first message matching the search query, starting
+// from message i and moving in the given direction
+func (m *Model) findMessage(i, direction int) {
+	query := strings.ToLower(m.findQuery)
+	if query == "" {
+		return
+	}
+	for ; i >= 0 && i < len(m.messages); i += direction {
+		if strings.Contains(strings.ToLower(m.messages[i].Content), query) {
+			// Search results are shown expanded
+			m.messages[i].Collapsed = false
+			m.selectMessage(i)
+			return
+		}
+	}
+}
+
+// isCollapsible returns true if the message can be collapsed in the transcript
+func isCollapsible(msg ChatMessage) bool {
+	return msg.Role == "reasoning" || msg.Role == "tool"
+}
+
+// collapse returns the first line of a collapsed message
+func collapse(content string) string {
+	first, _, more := strings.Cut(strings.TrimSpace(content), "\n")
+	if more {
+		first += " …"
+	}
+	return first
+}
+
+// navigationHelp renders the help line shown while navigating the transcript
+func (m Model) navigationHelp() string {
+	if m.finding {
+		return promptHintStyle.Render("(find) ") + m.findQuery
+	}
+
+	k := navigationKeys
+	entries := []string{}
+	for _, b := range []key.Binding{k.PageUp, k.PageDown, k.PreviousMessage, k.NextMessage, k.Toggle, k.Find, k.FindNext, k.Leave} {
+		entries = append(entries, helpEntry(b))
+	}
+	return helpStyle.Render(strings.Join(entries, " • "))
+}
@@ -90,4 +90,27 @@ var (
 	// Dimmed text style
 	dimmedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
+
+	// Warning style
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
+	// Tool result styles
+	exitSuccessStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("76")).
+				Bold(true)
+	exitFailureStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196")).
+				Bold(true)
+	stderrStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("203"))
+	toolResultBoxStyle = lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(lipgloss.Color("238")).
+				Padding(0, 1)
+
+	// Selected message marker style
+	selectedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Bold(true)
 )
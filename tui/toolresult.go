@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mudler/wiz/chat"
+)
+
+// maxToolOutputLines bounds the lines of tool output shown in the transcript
+const maxToolOutputLines = 20
+
+// renderToolResult renders the outcome of a tool run by the agent. Collapsed
+// results only show the tool name and exit code.
+func renderToolResult(result chat.ToolResult, collapsed bool) string {
+	var sb strings.Builder
+
+	sb.WriteString(toolNameStyle.Render("🔧 " + result.Name))
+	if result.IsCommand() {
+		exit := fmt.Sprintf(" exit %d", *result.ExitCode)
+		if result.Failed() {
+			sb.WriteString(exitFailureStyle.Render(exit))
+		} else {
+			sb.WriteString(exitSuccessStyle.Render(exit))
+		}
+	}
+	if collapsed {
+		sb.WriteString(dimmedStyle.Render(" (collapsed)"))
+		return toolResultBoxStyle.Render(sb.String())
+	}
+
+	if !result.IsCommand() {
+		if output := strings.TrimSpace(result.Output); output != "" {
+			sb.WriteString("\n")
+			sb.WriteString(chat.TrimLines(output, maxToolOutputLines))
+		}
+		return toolResultBoxStyle.Render(sb.String())
+	}
+
+	if stdout := strings.TrimSpace(result.Stdout); stdout != "" {
+		sb.WriteString("\n")
+		sb.WriteString(chat.TrimLines(stdout, maxToolOutputLines))
+	}
+	stderr := strings.TrimSpace(result.Stderr)
+	if stderr == "" {
+		stderr = result.Error
+	}
+	if stderr != "" {
+		sb.WriteString("\n")
+		sb.WriteString(stderrStyle.Render(chat.TrimLines(stderr, maxToolOutputLines)))
+	}
+	return toolResultBoxStyle.Render(sb.String())
+}
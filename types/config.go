@@ -2,8 +2,6 @@ package types
 
 import (
 	"bytes"
-	"os"
-	"os/user"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
@@ -17,6 +15,49 @@ type AgentOptions struct {
 	ForceReasoning bool `yaml:"force_reasoning"`
 }
 
+// ContextOptions selects which details about the environment are made
+// available to the prompt template. Everything is disabled by default.
+type ContextOptions struct {
+	OS          bool `yaml:"os"`
+	Shell       bool `yaml:"shell"`
+	Git         bool `yaml:"git"`
+	Project     bool `yaml:"project"`
+	History     int  `yaml:"history"` // Number of recent shell history lines, 0 disables
+	CommandLine bool `yaml:"command_line"`
+}
+
+// KeyBindings holds the keys used by the shell widgets and the TUI.
+// Keys are written like "ctrl+space", "alt+x" or, for sequences, "ctrl+x f".
+type KeyBindings struct {
+	// Shell widgets, an empty value means the default of each shell
+	Ask     string `yaml:"ask"`
+	Fix     string `yaml:"fix"`
+	Explain string `yaml:"explain"`
+
+	// TUI
+	Send       []string `yaml:"send"`
+	Quit       []string `yaml:"quit"`
+	UseCommand []string `yaml:"use_command"`
+	// InsertCommand inserts the command at the cursor instead of replacing the command line
+	InsertCommand []string `yaml:"insert_command"`
+	Complete      []string `yaml:"complete"`
+	Newline       []string `yaml:"newline"`
+
+	HistoryPrevious []string `yaml:"history_previous"`
+	HistoryNext     []string `yaml:"history_next"`
+	Search          []string `yaml:"search"`
+	Navigate        []string `yaml:"navigate"`
+}
+
+// TmuxOptions configures how wiz is shown when summoned inside tmux
+type TmuxOptions struct {
+	Mode   string `yaml:"mode"`   // "popup" or "split", defaults to popup when tmux supports it
+	Width  string `yaml:"width"`  // Popup width, e.g. "80%" or "100"
+	Height string `yaml:"height"` // Popup height, defaults to the --height flag
+	X      string `yaml:"x"`      // Popup position, e.g. "C", "R" or a column
+	Y      string `yaml:"y"`      // Popup position, e.g. "C", "S" or a line
+}
+
 // Config holds configuration for creating a new session
 type Config struct {
 	Model        string               `yaml:"model"`
@@ -26,39 +67,73 @@ type Config struct {
 	Prompt       string               `yaml:"prompt"`
 	MCPServers   map[string]MCPServer `yaml:"mcp_servers"`
 	AgentOptions AgentOptions         `yaml:"agent_options"`
+	Context      ContextOptions       `yaml:"context"`
+	KeyBindings  KeyBindings          `yaml:"keybindings"`
+	Tmux         TmuxOptions          `yaml:"tmux"`
+
+	// CommandLine is the command line being edited in the shell, as passed
+	// by the shell widget. It is set at runtime and never read from files.
+	CommandLine string `yaml:"-"`
+	// CommandLineCursor is the cursor position in CommandLine in runes, -1 for the end of the line
+	CommandLineCursor int `yaml:"-"`
+	// Shell is the shell that summoned wiz, as passed by the shell widget
+	Shell string `yaml:"-"`
+	// Secrets are the values redacted from logs, exports and tool outputs:
+	// the API key and the values read from secret references
+	Secrets []string `yaml:"-"`
 }
 
-func (c *Config) GetPrompt() string {
-	tmpl := template.New("").Funcs(sprig.FuncMap())
+// TemplateData is the data available to prompt templates and custom commands
+type TemplateData struct {
+	Config           Config
+	CurrentDirectory string
+	CurrentUser      string
 
-	data := bytes.NewBuffer([]byte{})
+	// Environment details, only filled in when enabled in the context options
+	OS           string
+	Shell        string
+	GitBranch    string
+	GitStatus    string
+	ProjectTypes []string
+	ShellHistory []string
+	CommandLine  string
 
-	currentDirectory, err := os.Getwd()
-	if err != nil {
-		currentDirectory = ""
-	}
-	currentUser, err := user.Current()
+	// Args holds the raw arguments given to a custom command
+	Args string
+	// Arguments holds the arguments given to a custom command, split on whitespace
+	Arguments []string
+}
+
+// RenderTemplate renders a Go template with the sprig functions available
+func RenderTemplate(text string, data any) (string, error) {
+	tmpl, err := template.New("").Funcs(sprig.FuncMap()).Parse(text)
 	if err != nil {
-		currentUser = &user.User{}
+		return "", err
 	}
 
-	if err := tmpl.Execute(data, struct {
-		Config           Config
-		CurrentDirectory string
-		CurrentUser      string
-	}{
-		Config:           *c,
-		CurrentDirectory: currentDirectory,
-		CurrentUser:      currentUser.Username,
-	}); err != nil {
-		return ""
+	buf := bytes.NewBuffer([]byte{})
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
 	}
 
-	return data.String()
+	return buf.String(), nil
 }
 
 type MCPServer struct {
+	// Local servers, started by wiz and reached over stdio
 	Command string            `yaml:"command"`
 	Args    []string          `yaml:"args"`
 	Env     map[string]string `yaml:"env"`
+
+	// Remote or already running servers, reached over HTTP
+	URL       string            `yaml:"url"`
+	Headers   map[string]string `yaml:"headers"`
+	Transport string            `yaml:"transport"` // "streamable-http" (default) or "sse"
+
+	// Tools given to the agent, by name or pattern like "write_*". All the
+	// tools are given if EnabledTools is empty.
+	EnabledTools  []string `yaml:"enabled_tools"`
+	DisabledTools []string `yaml:"disabled_tools"`
+	// ToolPrefix is prepended to the tool names, e.g. "fs_" to avoid collisions
+	ToolPrefix string `yaml:"tool_prefix"`
 }
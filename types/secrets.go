@@ -0,0 +1,86 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the secrets in redacted texts
+const redacted = "[REDACTED]"
+
+// minSecretLength keeps short values, likely not secrets, from being redacted
+// everywhere they appear
+const minSecretLength = 6
+
+// AddSecret records a value to redact from logs, exports and tool outputs
+func (c *Config) AddSecret(value string) {
+	if len(value) >= minSecretLength {
+		c.Secrets = append(c.Secrets, value)
+	}
+}
+
+// Redact replaces the secrets of the config found in text
+func (c *Config) Redact(text string) string {
+	for _, secret := range c.Secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// RedactHandler wraps a log handler to redact secrets from the messages and
+// attributes it logs
+func RedactHandler(handler slog.Handler, redact func(string) string) slog.Handler {
+	return &redactHandler{handler: handler, redact: redact}
+}
+
+type redactHandler struct {
+	handler slog.Handler
+	redact  func(string) string
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, h.redact(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		record.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redactedAttrs[i] = h.redactAttr(attr)
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(redactedAttrs), redact: h.redact}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{handler: h.handler.WithGroup(name), redact: h.redact}
+}
+
+// redactAttr redacts the value of an attribute, formatting non-string values
+func (h *redactHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		attrs := value.Group()
+		redactedAttrs := make([]any, len(attrs))
+		for i, a := range attrs {
+			redactedAttrs[i] = h.redactAttr(a)
+		}
+		return slog.Group(attr.Key, redactedAttrs...)
+	case slog.KindString:
+		return slog.String(attr.Key, h.redact(value.String()))
+	case slog.KindAny:
+		return slog.String(attr.Key, h.redact(fmt.Sprint(value.Any())))
+	default:
+		return attr
+	}
+}